@@ -0,0 +1,33 @@
+// Package agent implements a long-running process that holds mytokens in memory and serves (cached) access tokens
+// to clients over a unix socket.
+package agent
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// Request is the request a client sends to the agent
+type Request struct {
+	Mytoken   string   `json:"mytoken,omitempty"`
+	Scopes    []string `json:"scopes,omitempty"`
+	Audiences []string `json:"audiences,omitempty"`
+	Comment   string   `json:"comment,omitempty"`
+}
+
+// Response is the response the agent sends back to a client
+type Response struct {
+	AccessToken string `json:"access_token,omitempty"`
+	ExpiresAt   int64  `json:"expires_at,omitempty"`
+	Error       string `json:"error,omitempty"`
+}
+
+// DefaultSocketPath returns the path of the agent's socket if no other path is given;
+// it is located in XDG_RUNTIME_DIR if set and in the temp directory otherwise
+func DefaultSocketPath() string {
+	if dir := os.Getenv("XDG_RUNTIME_DIR"); dir != "" {
+		return filepath.Join(dir, "mytoken-agent.sock")
+	}
+	return filepath.Join(os.TempDir(), fmt.Sprintf("mytoken-agent-%d.sock", os.Getuid()))
+}
@@ -0,0 +1,28 @@
+package agent
+
+import (
+	"encoding/json"
+	"net"
+
+	"github.com/pkg/errors"
+)
+
+// GetAccessToken requests an access token from the agent listening on the passed socket
+func GetAccessToken(socket string, req Request) (*Response, error) {
+	conn, err := net.Dial("unix", socket)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not connect to mytoken agent")
+	}
+	defer conn.Close()
+	if err = json.NewEncoder(conn).Encode(req); err != nil {
+		return nil, err
+	}
+	var res Response
+	if err = json.NewDecoder(conn).Decode(&res); err != nil {
+		return nil, errors.Wrap(err, "could not read response from mytoken agent")
+	}
+	if res.Error != "" {
+		return nil, errors.New(res.Error)
+	}
+	return &res, nil
+}
@@ -0,0 +1,144 @@
+package agent
+
+import (
+	"encoding/json"
+	"net"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// FetchFunc obtains a new access token for the passed Request. If the expiration time of the access token is not
+// known, 0 is returned for expiresAt and the access token is not cached.
+type FetchFunc func(req Request) (accessToken string, expiresAt int64, err error)
+
+type cachedToken struct {
+	accessToken string
+	expiresAt   int64
+}
+
+// Server is the agent's server listening on a unix socket
+type Server struct {
+	socket   string
+	minValid time.Duration
+	fetch    FetchFunc
+
+	mutex    sync.Mutex
+	cache    map[string]cachedToken
+	listener net.Listener
+}
+
+// NewServer creates a new Server listening on the passed socket. Cached access tokens are reused as long as they are
+// valid for at least minValid.
+func NewServer(socket string, minValid time.Duration, fetch FetchFunc) *Server {
+	return &Server{
+		socket:   socket,
+		minValid: minValid,
+		fetch:    fetch,
+		cache:    make(map[string]cachedToken),
+	}
+}
+
+// ListenAndServe creates the socket and serves requests until Close is called
+func (s *Server) ListenAndServe() error {
+	if err := removeStaleSocket(s.socket); err != nil {
+		return err
+	}
+	l, err := net.Listen("unix", s.socket)
+	if err != nil {
+		return err
+	}
+	if err = os.Chmod(s.socket, 0600); err != nil {
+		_ = l.Close()
+		return err
+	}
+	s.listener = l
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return err
+		}
+		go s.handle(conn)
+	}
+}
+
+// Close stops the server and removes the socket
+func (s *Server) Close() error {
+	if s.listener == nil {
+		return nil
+	}
+	return s.listener.Close()
+}
+
+func removeStaleSocket(socket string) error {
+	if _, err := os.Stat(socket); err != nil {
+		return nil
+	}
+	if conn, err := net.Dial("unix", socket); err == nil {
+		_ = conn.Close()
+		return errors.Errorf("another agent is already listening on %s", socket)
+	}
+	return os.Remove(socket)
+}
+
+func (s *Server) handle(conn net.Conn) {
+	defer conn.Close()
+	var req Request
+	var res Response
+	if err := json.NewDecoder(conn).Decode(&req); err != nil {
+		res.Error = "invalid request: " + err.Error()
+	} else {
+		res = s.accessToken(req)
+	}
+	_ = json.NewEncoder(conn).Encode(res)
+}
+
+func (s *Server) accessToken(req Request) Response {
+	key := cacheKey(req)
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if c, ok := s.cache[key]; ok {
+		if time.Until(time.Unix(c.expiresAt, 0)) > s.minValid {
+			return Response{
+				AccessToken: c.accessToken,
+				ExpiresAt:   c.expiresAt,
+			}
+		}
+		delete(s.cache, key)
+	}
+	at, exp, err := s.fetch(req)
+	if err != nil {
+		return Response{Error: err.Error()}
+	}
+	if exp != 0 {
+		s.cache[key] = cachedToken{
+			accessToken: at,
+			expiresAt:   exp,
+		}
+	}
+	return Response{
+		AccessToken: at,
+		ExpiresAt:   exp,
+	}
+}
+
+func cacheKey(req Request) string {
+	scopes := append([]string{}, req.Scopes...)
+	sort.Strings(scopes)
+	auds := append([]string{}, req.Audiences...)
+	sort.Strings(auds)
+	return strings.Join(
+		[]string{
+			req.Mytoken,
+			strings.Join(scopes, " "),
+			strings.Join(auds, " "),
+		}, "|",
+	)
+}
@@ -0,0 +1,160 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	mytokenlib "github.com/oidc-mytoken/lib"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"github.com/urfave/cli/v3"
+
+	"github.com/oidc-mytoken/client/internal/agent"
+	"github.com/oidc-mytoken/client/internal/utils/tokenfile"
+)
+
+const agentDefaultMytoken = "default"
+
+var agentCommand = struct {
+	MTOptions
+	Socket   string
+	Load     []string
+	MinValid time.Duration
+}{}
+
+func init() {
+	app.Commands = append(
+		app.Commands, &cli.Command{
+			Name:  "agent",
+			Usage: "Run an agent that holds mytokens in memory and serves access tokens over a unix socket",
+			Description: "The agent loads the passed mytokens once and serves access tokens to 'mytoken AT --agent'. " +
+				"Access tokens are cached and reused until they are close to expiry. " +
+				"If a mytoken is rotated, the agent keeps the updated mytoken; " +
+				"mytokens loaded from a file or the mytoken store are also written back there.",
+			Action: runAgent,
+			Flags: appendMTFlags(
+				getAgentSocketFlag(&agentCommand.Socket),
+				&cli.StringSliceFlag{
+					Name: "load",
+					Usage: "Additionally load the mytoken from a file and make it available under a name; given as `NAME=FILE`. " +
+						"Can be used multiple times.",
+					TakesFile:   true,
+					Destination: &agentCommand.Load,
+				},
+				getMinValidFlag(&agentCommand.MinValid),
+			),
+		},
+	)
+}
+
+func getAgentSocketFlag(dest *string) cli.Flag {
+	return &cli.StringFlag{
+		Name:        "agent-socket",
+		Usage:       "The unix `SOCKET` the mytoken agent listens on",
+		Sources:     cli.EnvVars("MYTOKEN_AGENT_SOCKET"),
+		Value:       agent.DefaultSocketPath(),
+		TakesFile:   true,
+		Destination: dest,
+	}
+}
+
+type agentMytoken struct {
+	token     string
+	file      string
+	storeName string
+	server    *mytokenlib.MytokenServer
+}
+
+func newAgentMytoken(token, file, storeName string) (*agentMytoken, error) {
+	server, err := mytokenServerForToken(token)
+	if err != nil {
+		return nil, err
+	}
+	return &agentMytoken{
+		token:     token,
+		file:      file,
+		storeName: storeName,
+		server:    server,
+	}, nil
+}
+
+func (mt *agentMytoken) update(updatedToken string) {
+	mt.token = updatedToken
+	if _, err := storeUpdatedMytoken(mt.file, mt.storeName, updatedToken); err != nil {
+		log.Error(err)
+	}
+}
+
+func (mt *agentMytoken) accessToken(req agent.Request) (string, int64, error) {
+	atRes, err := obtainAT(mt.server, mt.token, req.Scopes, req.Audiences, req.Comment, mt.update)
+	if err != nil {
+		return "", 0, err
+	}
+	return atRes.AccessToken, accessTokenExpiresAt(atRes), nil
+}
+
+func loadAgentMytokens() (map[string]*agentMytoken, error) {
+	mytokens := make(map[string]*agentMytoken)
+	if agentCommand.SSH() != "" {
+		return nil, errors.New("the mytoken agent cannot be used with --ssh")
+	}
+	if token := agentCommand.GetToken(); token != "" {
+		mt, err := newAgentMytoken(token, agentCommand.MytokenFile(), agentCommand.MytokenName())
+		if err != nil {
+			return nil, err
+		}
+		mytokens[agentDefaultMytoken] = mt
+	}
+	for _, l := range agentCommand.Load {
+		name, file, found := strings.Cut(l, "=")
+		if !found || name == "" || file == "" {
+			return nil, errors.Errorf("invalid value '%s' for --load; must be NAME=FILE", l)
+		}
+		token, err := tokenfile.Read(file)
+		if err != nil {
+			return nil, err
+		}
+		mt, err := newAgentMytoken(token, file, "")
+		if err != nil {
+			return nil, err
+		}
+		mytokens[name] = mt
+	}
+	if len(mytokens) == 0 {
+		return nil, errors.New("No mytoken provided.")
+	}
+	return mytokens, nil
+}
+
+func runAgent(ctx context.Context, _ *cli.Command) error {
+	mytokens, err := loadAgentMytokens()
+	if err != nil {
+		return err
+	}
+	server := agent.NewServer(
+		agentCommand.Socket, agentCommand.MinValid, func(req agent.Request) (string, int64, error) {
+			name := req.Mytoken
+			if name == "" {
+				name = agentDefaultMytoken
+			}
+			mt, ok := mytokens[name]
+			if !ok {
+				return "", 0, errors.Errorf("no mytoken loaded with name '%s'", name)
+			}
+			return mt.accessToken(req)
+		},
+	)
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		_ = server.Close()
+	}()
+	fmt.Printf("MYTOKEN_AGENT_SOCKET=%s; export MYTOKEN_AGENT_SOCKET;\n", agentCommand.Socket)
+	return server.ListenAndServe()
+}
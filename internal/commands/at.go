@@ -2,22 +2,44 @@ package commands
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
+	"fmt"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
 
 	"github.com/oidc-mytoken/api/v0"
 	mytokenlib "github.com/oidc-mytoken/lib"
+	"github.com/oidc-mytoken/utils/utils/jwtutils"
+	log "github.com/sirupsen/logrus"
 	"github.com/urfave/cli/v3"
 
+	"github.com/oidc-mytoken/client/internal/agent"
 	"github.com/oidc-mytoken/client/internal/config"
+	"github.com/oidc-mytoken/client/internal/store"
 	cutils "github.com/oidc-mytoken/client/internal/utils"
+	"github.com/oidc-mytoken/client/internal/utils/atcache"
+	"github.com/oidc-mytoken/client/internal/utils/tokenfile"
+	"github.com/oidc-mytoken/client/internal/utils/wlcgtokendiscovery"
 )
 
 var atCommand = struct {
 	MTOptions
-	Scopes    []string
-	Audiences []string
-	Out       string
+	Scopes       []string
+	Audiences    []string
+	Out          string
+	UseAgent     bool
+	AgentSocket  string
+	AgentMytoken string
+	NoCache      bool
+	MinValid     time.Duration
+	WLCG         bool
+	Watch        bool
 }{}
 
 func init() {
@@ -31,55 +53,351 @@ func init() {
 			Usage:  "Obtain an OIDC access token",
 			Action: getAT,
 			Flags: appendMTFlags(
-				&cli.StringSliceFlag{
-					Name:        "scope",
-					Aliases:     []string{"s"},
-					Usage:       "Request the passed scope.",
-					DefaultText: "all scopes allowed for the used mytoken",
-					Destination: &atCommand.Scopes,
-				},
-				&cli.StringSliceFlag{
-					Name:        "aud",
-					Aliases:     []string{"audience"},
-					Usage:       "Request the passed audience.",
-					Destination: &atCommand.Audiences,
-				},
-				&cli.StringFlag{
-					Name:        "out",
-					Aliases:     []string{"o"},
-					Usage:       "The access token will be printed to this `FILE`",
-					TakesFile:   true,
-					Value:       os.Stdout.Name(),
-					Destination: &atCommand.Out,
-				},
+				append(
+					getScopeAudienceFlags(&atCommand.Scopes, &atCommand.Audiences),
+					&cli.StringFlag{
+						Name:        "out",
+						Aliases:     []string{"o"},
+						Usage:       "The access token will be printed to this `FILE`",
+						TakesFile:   true,
+						Value:       os.Stdout.Name(),
+						Destination: &atCommand.Out,
+					},
+					&cli.BoolFlag{
+						Name:        "agent",
+						Usage:       "Obtain the access token from a running mytoken agent instead of the mytoken server",
+						Destination: &atCommand.UseAgent,
+					},
+					getAgentSocketFlag(&atCommand.AgentSocket),
+					&cli.StringFlag{
+						Name: "agent-mytoken",
+						Usage: "Use the mytoken loaded into the agent under `NAME`; " +
+							"only used together with --agent",
+						DefaultText: "the agent's default mytoken",
+						Destination: &atCommand.AgentMytoken,
+					},
+					getNoCacheFlag(&atCommand.NoCache),
+					getMinValidFlag(&atCommand.MinValid),
+					&cli.BoolFlag{
+						Name: "wlcg",
+						Usage: "Write the access token to the location where WLCG Bearer Token Discovery finds it first " +
+							"(BEARER_TOKEN_FILE, $XDG_RUNTIME_DIR/bt_u<uid>, or /tmp/bt_u<uid>) instead of --out",
+						Destination: &atCommand.WLCG,
+					},
+					&cli.BoolFlag{
+						Name: "watch",
+						Usage: "Keep running and renew the access token when it is valid for less than --min-valid; " +
+							"the new access token is written to the same location",
+						Destination: &atCommand.Watch,
+					},
+				)...,
 			),
 		},
 	)
 }
 
-func getAT(_ context.Context, cmd *cli.Command) error {
+func getScopeAudienceFlags(scopes, audiences *[]string) []cli.Flag {
+	return []cli.Flag{
+		&cli.StringSliceFlag{
+			Name:        "scope",
+			Aliases:     []string{"s"},
+			Usage:       "Request the passed scope.",
+			DefaultText: "all scopes allowed for the used mytoken",
+			Destination: scopes,
+		},
+		&cli.StringSliceFlag{
+			Name:        "aud",
+			Aliases:     []string{"audience"},
+			Usage:       "Request the passed audience.",
+			Destination: audiences,
+		},
+	}
+}
+
+func getNoCacheFlag(dest *bool) cli.Flag {
+	return &cli.BoolFlag{
+		Name: "no-cache",
+		Usage: "Always obtain a new access token from the mytoken server and do not use the access token " +
+			"cache in XDG_RUNTIME_DIR",
+		Destination: dest,
+	}
+}
+
+func getMinValidFlag(dest *time.Duration) cli.Flag {
+	return &cli.DurationFlag{
+		Name:        "min-valid",
+		Usage:       "Cached access tokens are only reused if they are valid for at least this `DURATION`",
+		Value:       time.Minute,
+		Destination: dest,
+	}
+}
+
+func getAT(ctx context.Context, cmd *cli.Command) error {
 	atc := atCommand
 	var comment string
 	if cmd.Args().Len() > 0 {
 		comment = cmd.Args().Get(0)
 	}
-	if ssh := atc.SSH(); ssh != "" {
-		req := mytokenlib.NewAccessTokenRequest("", "", atc.Scopes, atc.Audiences, comment)
-		return doSSH(ssh, api.SSHRequestAccessToken, req)
+	var fetch accessTokenFetchFunc
+	if atc.UseAgent {
+		fetch = agentAccessTokenFetcher(
+			atc.AgentSocket, agent.Request{
+				Mytoken:   atc.AgentMytoken,
+				Scopes:    atc.Scopes,
+				Audiences: atc.Audiences,
+				Comment:   comment,
+			},
+		)
+	} else {
+		fetch = accessTokenFetcher(atc.MTOptions, atc.Scopes, atc.Audiences, comment, !atc.NoCache)
 	}
-	mToken := atc.MustGetToken()
-	mytoken := config.Get().Mytoken()
-	atRes, err := mytoken.AccessToken.APIGet(
-		mToken, "", atc.Scopes, atc.Audiences, comment,
-	)
+	write := func(at string) error {
+		return cutils.WriteOutput(atc.Out, at)
+	}
+	if atc.WLCG {
+		if err := checkWLCGTarget(atc.MTOptions); err != nil {
+			return err
+		}
+		if wlcgtokendiscovery.ShadowedByEnv() {
+			_, _ = fmt.Fprintln(
+				os.Stderr, "Warning: BEARER_TOKEN is set; "+
+					"WLCG Bearer Token Discovery will use it instead of the written access token",
+			)
+		}
+		write = writeWLCGToken
+	}
+	at, exp, err := fetch(atc.MinValid)
 	if err != nil {
 		return err
 	}
+	if err = write(at); err != nil {
+		return err
+	}
+	if !atc.Watch {
+		return nil
+	}
+	return watchAccessToken(ctx, fetch, write, exp, atc.MinValid)
+}
+
+// accessTokenFetchFunc obtains an access token that is valid for at least minValid and returns it together with its
+// expiration time (0 if unknown)
+type accessTokenFetchFunc func(minValid time.Duration) (string, int64, error)
+
+// accessTokenFetcher returns an accessTokenFetchFunc that uses the mytoken from the passed MTOptions
+func accessTokenFetcher(
+	mtOpts MTOptions, scopes, audiences []string, comment string, useCache bool,
+) accessTokenFetchFunc {
+	if ssh := mtOpts.SSH(); ssh != "" {
+		return func(time.Duration) (string, int64, error) {
+			req := mytokenlib.NewAccessTokenRequest("", "", scopes, audiences, comment)
+			at, err := doSSHReturnOutput(ssh, api.SSHRequestAccessToken, req)
+			if err != nil {
+				return "", 0, err
+			}
+			at = strings.TrimSpace(at)
+			return at, accessTokenExpiresAt(&api.AccessTokenResponse{AccessToken: at}), nil
+		}
+	}
+	mToken := mtOpts.MustGetToken()
+	var cacheID string
+	if useCache {
+		cacheID = mytokenCacheID(mToken)
+	}
+	return func(minValid time.Duration) (string, int64, error) {
+		return getAccessToken(mToken, cacheID, scopes, audiences, comment, minValid)
+	}
+}
+
+// agentAccessTokenFetcher returns an accessTokenFetchFunc that obtains the access token from a mytoken agent
+func agentAccessTokenFetcher(socket string, req agent.Request) accessTokenFetchFunc {
+	return func(time.Duration) (string, int64, error) {
+		res, err := agent.GetAccessToken(socket, req)
+		if err != nil {
+			return "", 0, err
+		}
+		return res.AccessToken, res.ExpiresAt, nil
+	}
+}
+
+// checkWLCGTarget refuses to write the access token to the WLCG Bearer Token Discovery location if the mytoken is
+// stored there, because the mytoken is discovered from the same locations and would be overwritten
+func checkWLCGTarget(mtOpts MTOptions) error {
+	target := wlcgtokendiscovery.TokenFileLocation()
+	if f := mtOpts.MytokenFile(); f != "" && sameFile(f, target) {
+		return fmt.Errorf(
+			"the mytoken is read from %s, where --wlcg would write the access token; "+
+				"use --out to write the access token to another file", target,
+		)
+	}
+	content, err := os.ReadFile(target)
+	if err != nil {
+		return nil
+	}
+	if holdsMytoken(content) {
+		return fmt.Errorf(
+			"%s holds a mytoken, which would be overwritten by the access token; "+
+				"use --out to write the access token to another file", target,
+		)
+	}
+	return nil
+}
+
+// holdsMytoken checks if the content of a token file is a mytoken; encrypted token files only hold mytokens
+func holdsMytoken(content []byte) bool {
+	if tokenfile.IsEncrypted(content) {
+		return true
+	}
+	mt, ok := mytokenClaims(strings.TrimSpace(string(content)))
+	return ok && (mt.OIDCIssuer != "" || len(mt.Capabilities) > 0)
+}
+
+// sameFile checks if two paths refer to the same file
+func sameFile(a, b string) bool {
+	aInfo, errA := os.Stat(a)
+	bInfo, errB := os.Stat(b)
+	if errA == nil && errB == nil {
+		return os.SameFile(aInfo, bInfo)
+	}
+	absA, errA := filepath.Abs(a)
+	absB, errB := filepath.Abs(b)
+	return errA == nil && errB == nil && absA == absB
+}
+
+func writeWLCGToken(at string) error {
+	file, err := wlcgtokendiscovery.WriteToken(at)
+	if err != nil {
+		return fmt.Errorf("could not write access token to %s: %w", file, err)
+	}
+	return nil
+}
+
+const (
+	// minWatchInterval is the minimum time between two renewals in watch mode
+	minWatchInterval = 10 * time.Second
+	// watchRetryInterval is the time after which a failed renewal is retried first in watch mode
+	watchRetryInterval = 30 * time.Second
+	// maxRetryInterval is the maximum time after which a failed renewal is retried
+	maxRetryInterval = 5 * time.Minute
+)
+
+// watchAccessToken renews the access token minValid before it expires and writes the new access token, until it is
+// interrupted
+func watchAccessToken(
+	ctx context.Context, fetch accessTokenFetchFunc, write func(string) error, exp int64, minValid time.Duration,
+) error {
+	if exp == 0 {
+		return errors.New("cannot watch the access token, because its expiration time is unknown")
+	}
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	wait := max(time.Until(time.Unix(exp, 0))-minValid, minWatchInterval)
+	var retry time.Duration
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(wait):
+		}
+		at, newExp, err := fetch(minValid)
+		if err == nil {
+			err = write(at)
+		}
+		if err != nil {
+			retry = nextRetryInterval(retry)
+			log.WithError(err).Errorf("could not renew access token, retrying in %s", retry)
+			wait = retry
+			continue
+		}
+		retry = 0
+		if newExp == 0 {
+			return errors.New("cannot watch the access token, because its expiration time is unknown")
+		}
+		wait = max(time.Until(time.Unix(newExp, 0))-minValid, minWatchInterval)
+	}
+}
+
+// getAccessToken returns an access token for the passed mytoken and when it expires (0 if unknown); if a cacheID
+// (see mytokenCacheID) is passed, a cached access token that is valid for at least minValid is returned and new
+// access tokens are cached
+func getAccessToken(
+	mToken, cacheID string, scopes, audiences []string, comment string, minValid time.Duration,
+) (string, int64, error) {
+	var cacheKey string
+	if cacheID != "" {
+		cacheKey = atcache.Key(config.Get().URL, cacheID, scopes, audiences)
+		if at, exp, ok := atcache.Get(cacheKey, minValid); ok {
+			return at, exp, nil
+		}
+	}
+	atRes, err := obtainAT(config.Get().Mytoken(), mToken, scopes, audiences, comment, updateMytoken)
+	if err != nil {
+		return "", 0, err
+	}
+	exp := accessTokenExpiresAt(atRes)
+	if cacheKey != "" && exp != 0 {
+		if err = atcache.Store(cacheKey, atRes.AccessToken, exp); err != nil {
+			log.WithError(err).Error("could not cache access token")
+		}
+	}
+	return atRes.AccessToken, exp, nil
+}
+
+// mytokenCacheID returns a value that identifies the passed mytoken in the access token cache without contacting
+// the server; it is the MOM-ID if the mytoken is in the mytoken store, so it does not change when the mytoken is
+// rotated, otherwise see mytokenID
+func mytokenCacheID(mToken string) string {
+	if s, err := store.Load(); err == nil {
+		for _, e := range s.Entries {
+			if e.Mytoken == mToken && e.MOMID != "" {
+				return e.MOMID
+			}
+		}
+	}
+	return mytokenID(mToken)
+}
+
+// mytokenID returns a value that identifies the passed mytoken without contacting the server; for JWTs this is the
+// token id, for other mytokens a hash of the token
+func mytokenID(mToken string) string {
+	if jwtutils.IsJWT(mToken) {
+		if id, ok := jwtutils.GetStringFromJWT(log.StandardLogger(), mToken, "jti"); ok {
+			return id
+		}
+	}
+	h := sha256.Sum256([]byte(mToken))
+	return hex.EncodeToString(h[:])
+}
+
+// obtainAT uses the passed mytoken to obtain an access token from the passed mytoken server; if the mytoken was
+// rotated, the updated mytoken is passed to updateMT
+func obtainAT(
+	mytoken *mytokenlib.MytokenServer, mToken string, scopes, audiences []string, comment string,
+	updateMT func(string),
+) (*api.AccessTokenResponse, error) {
+	atRes, err := mytoken.AccessToken.APIGet(mToken, "", scopes, audiences, comment)
+	if err != nil {
+		return nil, err
+	}
 	if atRes.AccessToken == "" {
-		return errors.New("server returned empty access token")
+		return nil, errors.New("server returned empty access token")
 	}
 	if atRes.TokenUpdate != nil {
-		updateMytoken(atRes.TokenUpdate.Mytoken)
+		updateMT(atRes.TokenUpdate.Mytoken)
+	}
+	return &atRes, nil
+}
+
+// accessTokenExpiresAt returns the unix timestamp when the passed access token expires or 0 if unknown
+func accessTokenExpiresAt(atRes *api.AccessTokenResponse) int64 {
+	if atRes.ExpiresIn > 0 {
+		return time.Now().Unix() + atRes.ExpiresIn
+	}
+	if !jwtutils.IsJWT(atRes.AccessToken) {
+		return 0
+	}
+	exp, ok := jwtutils.GetValueFromJWT(log.StandardLogger(), atRes.AccessToken, "exp").(float64)
+	if !ok {
+		return 0
 	}
-	return cutils.WriteOutput(atc.Out, atRes.AccessToken)
+	return int64(exp)
 }
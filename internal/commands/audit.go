@@ -0,0 +1,277 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/oidc-mytoken/api/v0"
+	"github.com/oidc-mytoken/utils/utils/issuerutils"
+	"github.com/pkg/errors"
+	"github.com/urfave/cli/v3"
+
+	"github.com/oidc-mytoken/client/internal/config"
+	"github.com/oidc-mytoken/client/internal/store"
+	"github.com/oidc-mytoken/client/internal/utils/color"
+	"github.com/oidc-mytoken/client/internal/utils/tablewriter"
+)
+
+var auditOptions = struct {
+	MTOptions
+	MaxSubtokens int
+}{}
+
+// The severities of audit findings
+const (
+	auditSeverityLow    = "low"
+	auditSeverityMedium = "medium"
+	auditSeverityHigh   = "high"
+)
+
+var auditSeverityRank = map[string]int{
+	auditSeverityLow:    1,
+	auditSeverityMedium: 2,
+	auditSeverityHigh:   3,
+}
+
+var auditSeverityColor = map[string]string{
+	auditSeverityMedium: "FFA500",
+	auditSeverityHigh:   "FF0000",
+}
+
+func init() {
+	app.Commands = append(
+		app.Commands, &cli.Command{
+			Name: "audit",
+			Usage: "Audits your mytokens for risky settings, such as mytokens that do not expire, " +
+				"have broad capabilities, or can be used from anywhere; findings are ranked by severity",
+			Description: "All mytokens in the list of mytokens are checked for their expiration and number of " +
+				"subtokens. Capabilities, restrictions, and rotation are only known for mytokens that are available " +
+				"to the client, i.e. the used mytoken and the mytokens in the local mytoken store.",
+			Action: audit,
+			Flags: appendMTFlags(
+				&cli.IntFlag{
+					Name:        "max-subtokens",
+					Usage:       "Report mytokens with more than `N` subtokens",
+					Value:       25,
+					Destination: &auditOptions.MaxSubtokens,
+				},
+			),
+		},
+	)
+}
+
+type auditFinding struct {
+	Severity string `json:"severity"`
+	Check    string `json:"check"`
+	Name     string `json:"name,omitempty"`
+	MOMID    string `json:"mom_id"`
+	Message  string `json:"message"`
+}
+
+func audit(_ context.Context, _ *cli.Command) error {
+	var tree []api.MytokenEntryTree
+	var introspection *api.TokeninfoIntrospectResponse
+	if ssh := auditOptions.SSH(); ssh != "" {
+		res, err := doSSHParseJSON[api.TokeninfoListResponse](ssh, api.SSHRequestTokenInfoListMytokens, nil)
+		if err != nil {
+			return err
+		}
+		tree = res.Tokens
+		introspection, err = doSSHParseJSON[api.TokeninfoIntrospectResponse](
+			ssh, api.SSHRequestTokenInfoIntrospect, nil,
+		)
+		if err != nil {
+			return errors.Wrap(err, "could not introspect the used mytoken")
+		}
+	} else {
+		mToken := auditOptions.MustGetToken()
+		mytoken := config.Get().Mytoken()
+		res, err := mytoken.Tokeninfo.APIListMytokens(mToken)
+		if err != nil {
+			return err
+		}
+		if res.TokenUpdate != nil {
+			updateMytoken(res.TokenUpdate.Mytoken)
+			mToken = res.TokenUpdate.Mytoken
+		}
+		tree = res.Tokens
+		introspection, err = mytoken.Tokeninfo.Introspect(mToken)
+		if err != nil {
+			return errors.Wrap(err, "could not introspect the used mytoken")
+		}
+	}
+	details := map[string]api.Mytoken{}
+	if introspection.Valid && introspection.MOMID != "" {
+		details[introspection.MOMID] = usedMytokenClaims(introspection.Token)
+	}
+	addStoredMytokenClaims(details)
+
+	var findings []auditFinding
+	audited, inspected := 0, 0
+	var walk func(entries []api.MytokenEntryTree)
+	walk = func(entries []api.MytokenEntryTree) {
+		for _, e := range entries {
+			audited++
+			mt, known := details[e.Token.MOMID]
+			if known {
+				inspected++
+			}
+			findings = append(findings, auditMytoken(e, mt, known)...)
+			walk(e.Children)
+		}
+	}
+	walk(tree)
+	sort.SliceStable(
+		findings, func(i, j int) bool {
+			return auditSeverityRank[findings[i].Severity] > auditSeverityRank[findings[j].Severity]
+		},
+	)
+
+	if tablewriter.Structured() {
+		return tablewriter.PrintData(findings)
+	}
+	if len(findings) == 0 {
+		fmt.Println("No findings.")
+	} else {
+		data := make([]tablewriter.TableWriter, len(findings))
+		for i, f := range findings {
+			data[i] = tableAuditFinding(f)
+		}
+		tablewriter.PrintTableData(data)
+	}
+	_, _ = fmt.Fprintf(
+		os.Stderr, "Audited %s; capabilities, restrictions, and rotation were checked for %d of them, "+
+			"the others are not available to this client.\n", numMytokens(audited), inspected,
+	)
+	return nil
+}
+
+// auditMytoken checks a single mytoken; the details are only checked if they are known
+func auditMytoken(e api.MytokenEntryTree, mt api.Mytoken, known bool) []auditFinding {
+	var findings []auditFinding
+	add := func(severity, check, msg string) {
+		findings = append(
+			findings, auditFinding{
+				Severity: severity,
+				Check:    check,
+				Name:     e.Token.Name,
+				MOMID:    e.Token.MOMID,
+				Message:  msg,
+			},
+		)
+	}
+	if e.Token.ExpiresAt == 0 {
+		add(auditSeverityMedium, "never_expires", "does not expire")
+	}
+	if n := countSubtokens(e.Children); n > auditOptions.MaxSubtokens {
+		add(auditSeverityMedium, "many_subtokens", fmt.Sprintf("has %d subtokens", n))
+	}
+	if !known {
+		return findings
+	}
+	for _, c := range mt.Capabilities {
+		switch c.Name {
+		case api.CapabilityManageMTs.Name:
+			add(auditSeverityHigh, "broad_capability", "can manage and revoke any of your mytokens ("+c.Name+")")
+		case api.CapabilityRevokeAnyToken.Name:
+			add(auditSeverityHigh, "broad_capability", "can revoke any of your mytokens ("+c.Name+")")
+		case api.CapabilitySettings.Name:
+			add(auditSeverityHigh, "broad_capability", "can change your user settings ("+c.Name+")")
+		case api.CapabilityCreateMT.Name:
+			if len(mt.Restrictions) == 0 {
+				add(
+					auditSeverityHigh, "broad_capability",
+					"can create new mytokens without restrictions ("+c.Name+")",
+				)
+			}
+		}
+	}
+	switch {
+	case len(mt.Restrictions) == 0:
+		add(auditSeverityMedium, "no_restrictions", "has no restrictions")
+	case !restrictsNetwork(mt.Restrictions):
+		add(auditSeverityLow, "no_network_restriction", "can be used from any IP address and country")
+	}
+	if mt.Rotation == nil || (!mt.Rotation.OnAT && !mt.Rotation.OnOther) {
+		add(auditSeverityLow, "rotation_disabled", "is not rotated")
+	}
+	return findings
+}
+
+// restrictsNetwork checks if every restriction clause limits the hosts or countries the mytoken can be used from
+func restrictsNetwork(restrictions api.Restrictions) bool {
+	for _, r := range restrictions {
+		if r == nil {
+			continue
+		}
+		if len(r.Hosts) == 0 && len(r.GeoIPAllow) == 0 && len(r.GeoIPDisallow) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// usedMytokenClaims converts an introspected mytoken into its claims
+func usedMytokenClaims(used api.UsedMytoken) api.Mytoken {
+	mt := used.Mytoken
+	mt.Restrictions = nil
+	for _, r := range used.Restrictions {
+		restriction := r.Restriction
+		mt.Restrictions = append(mt.Restrictions, &restriction)
+	}
+	return mt
+}
+
+// addStoredMytokenClaims adds the claims of the mytokens of this instance in the local mytoken store that are not
+// known yet
+func addStoredMytokenClaims(details map[string]api.Mytoken) {
+	s, err := store.Load()
+	if err != nil {
+		return
+	}
+	for _, e := range s.Entries {
+		if e.MOMID == "" || !issuerutils.CompareIssuerURLs(config.Get().URL, e.Issuer) {
+			continue
+		}
+		if _, ok := details[e.MOMID]; ok {
+			continue
+		}
+		if mt, ok := mytokenClaims(e.Mytoken); ok {
+			details[e.MOMID] = mt
+		}
+	}
+}
+
+type tableAuditFinding auditFinding
+
+func (tableAuditFinding) TableGetHeader() []string {
+	return []string{
+		"Severity",
+		"Name",
+		"MOM-ID",
+		"Finding",
+	}
+}
+
+func (f tableAuditFinding) TableGetRow() []string {
+	severity := f.Severity
+	if c, ok := auditSeverityColor[severity]; ok {
+		severity = color.ColorizeText(severity, c)
+	}
+	name := f.Name
+	if name == "" {
+		name = color.Italic("unnamed token")
+	}
+	return []string{
+		severity,
+		name,
+		f.MOMID,
+		f.Message,
+	}
+}
+
+func (f tableAuditFinding) TableGetData() any {
+	return auditFinding(f)
+}
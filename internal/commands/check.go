@@ -0,0 +1,422 @@
+package commands
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/oidc-mytoken/api/v0"
+	mytokenlib "github.com/oidc-mytoken/lib"
+	"github.com/oidc-mytoken/utils/utils/duration"
+	"github.com/pkg/errors"
+	"github.com/urfave/cli/v3"
+
+	"github.com/oidc-mytoken/client/internal/config"
+	"github.com/oidc-mytoken/client/internal/utils/timeexpr"
+)
+
+var checkOptions = struct {
+	MTOptions
+	Warn         string
+	Crit         string
+	WarnUsagesAT int64
+	CritUsagesAT int64
+	All          bool
+}{}
+
+// The states and exit codes of monitoring plugins
+const (
+	checkOK       = 0
+	checkWarning  = 1
+	checkCritical = 2
+	checkUnknown  = 3
+)
+
+var checkStateNames = map[int]string{
+	checkOK:       "OK",
+	checkWarning:  "WARNING",
+	checkCritical: "CRITICAL",
+	checkUnknown:  "UNKNOWN",
+}
+
+// checkStateSeverity orders the states, so that the worst state is reported
+var checkStateSeverity = map[int]int{
+	checkOK:       0,
+	checkWarning:  1,
+	checkUnknown:  2,
+	checkCritical: 3,
+}
+
+func init() {
+	app.Commands = append(
+		app.Commands, &cli.Command{
+			Name: "check",
+			Usage: "Checks if a mytoken expires soon or runs out of access token usages; " +
+				"the output and exit code follow the conventions of Nagios / Icinga plugins",
+			Action: check,
+			Flags: appendMTFlags(
+				&cli.StringFlag{
+					Name:        "warn",
+					Aliases:     []string{"w"},
+					Usage:       "Report WARNING if the mytoken expires within `DURATION`",
+					Value:       "14d",
+					Destination: &checkOptions.Warn,
+				},
+				&cli.StringFlag{
+					Name:        "crit",
+					Aliases:     []string{"c"},
+					Usage:       "Report CRITICAL if the mytoken expires within `DURATION`",
+					Value:       "2d",
+					Destination: &checkOptions.Crit,
+				},
+				&cli.Int64Flag{
+					Name:        "warn-usages-at",
+					Usage:       "Report WARNING if at most `N` access tokens can be obtained with the mytoken",
+					Value:       10,
+					Destination: &checkOptions.WarnUsagesAT,
+				},
+				&cli.Int64Flag{
+					Name:        "crit-usages-at",
+					Usage:       "Report CRITICAL if at most `N` access tokens can be obtained with the mytoken",
+					Value:       1,
+					Destination: &checkOptions.CritUsagesAT,
+				},
+				&cli.BoolFlag{
+					Name:        "all",
+					Usage:       "Also check the expiration of all mytokens in the list of mytokens that are not expired",
+					Destination: &checkOptions.All,
+				},
+			),
+		},
+	)
+}
+
+// checkResult collects the outcome of the checks
+type checkResult struct {
+	state    int
+	problems []string
+	infos    []string
+	perfdata []string
+}
+
+func (r *checkResult) report(state int, msg string) {
+	if checkStateSeverity[state] > checkStateSeverity[r.state] {
+		r.state = state
+	}
+	if state == checkOK {
+		r.infos = append(r.infos, msg)
+	} else {
+		r.problems = append(r.problems, msg)
+	}
+}
+
+func (r *checkResult) addPerfdata(label string, value, warn, crit int64, unit string) {
+	if strings.ContainsAny(label, " ='") {
+		label = "'" + strings.ReplaceAll(label, "'", "''") + "'"
+	}
+	r.perfdata = append(r.perfdata, fmt.Sprintf("%s=%d%s;%d;%d;0", label, value, unit, warn, crit))
+}
+
+func (r *checkResult) String() string {
+	msgs := r.problems
+	if len(msgs) == 0 {
+		msgs = r.infos
+	}
+	s := fmt.Sprintf("MYTOKEN %s - %s", checkStateNames[r.state], strings.Join(msgs, "; "))
+	if len(r.perfdata) > 0 {
+		s += " | " + strings.Join(r.perfdata, " ")
+	}
+	return s
+}
+
+type checkThresholds struct {
+	warn, crit                 time.Duration
+	warnUsagesAT, critUsagesAT int64
+}
+
+func parseCheckThresholds() (*checkThresholds, error) {
+	warn, err := duration.ParseDuration(checkOptions.Warn)
+	if err != nil {
+		return nil, errors.Errorf("invalid value for --warn: '%s' is not a duration", checkOptions.Warn)
+	}
+	crit, err := duration.ParseDuration(checkOptions.Crit)
+	if err != nil {
+		return nil, errors.Errorf("invalid value for --crit: '%s' is not a duration", checkOptions.Crit)
+	}
+	if crit > warn {
+		return nil, errors.New("--crit must not be longer than --warn")
+	}
+	if checkOptions.CritUsagesAT > checkOptions.WarnUsagesAT {
+		return nil, errors.New("--crit-usages-at must not be larger than --warn-usages-at")
+	}
+	return &checkThresholds{
+		warn:         warn,
+		crit:         crit,
+		warnUsagesAT: checkOptions.WarnUsagesAT,
+		critUsagesAT: checkOptions.CritUsagesAT,
+	}, nil
+}
+
+func check(_ context.Context, _ *cli.Command) error {
+	result := &checkResult{}
+	thresholds, err := parseCheckThresholds()
+	if err != nil {
+		result.report(checkUnknown, err.Error())
+	} else {
+		runChecks(result, thresholds)
+	}
+	fmt.Println(result.String())
+	if result.state != checkOK {
+		return cli.Exit("", result.state)
+	}
+	return nil
+}
+
+// mytokenInstance returns the url of the mytoken instance that issued the passed mytoken; if this is not known
+// from the token, the configured instance is returned
+func mytokenInstance(mToken string) string {
+	if mt, ok := mytokenClaims(mToken); ok && mt.Issuer != "" {
+		return mt.Issuer
+	}
+	return config.Get().URL
+}
+
+func runChecks(result *checkResult, thresholds *checkThresholds) {
+	now := time.Now()
+	var introspection *api.TokeninfoIntrospectResponse
+	var mToken string
+	var mytoken *mytokenlib.MytokenServer
+	var err error
+	if ssh := checkOptions.SSH(); ssh != "" {
+		introspection, err = doSSHParseJSON[api.TokeninfoIntrospectResponse](
+			ssh, api.SSHRequestTokenInfoIntrospect, nil,
+		)
+	} else {
+		// the token is not obtained with GetToken and the server is not taken from the config, since both exit if
+		// the mytoken instance cannot be reached; the claims can still be checked then
+		mToken = checkOptions._getToken()
+		if mToken == "" {
+			result.report(checkUnknown, "no mytoken provided")
+			return
+		}
+		mytoken, err = mytokenlib.NewMytokenServer(mytokenInstance(mToken))
+		if err == nil {
+			introspection, err = mytoken.Tokeninfo.Introspect(mToken)
+		}
+	}
+
+	var mt api.UsedMytoken
+	usagesKnown := false
+	switch {
+	case err == nil && !introspection.Valid:
+		result.report(checkCritical, "the mytoken is not valid; it expired or was revoked")
+		return
+	case err == nil:
+		mt = introspection.Token
+		usagesKnown = true
+	default:
+		claims, ok := mytokenClaims(mToken)
+		if !ok {
+			result.report(checkUnknown, fmt.Sprintf("could not introspect the mytoken: %s", err))
+			return
+		}
+		// the claims of the token tell when it expires, but not if it was revoked or how often it was used
+		result.report(checkUnknown, fmt.Sprintf("could not introspect the mytoken, checking its claims only: %s", err))
+		mt.Mytoken = claims
+		for _, r := range claims.Restrictions {
+			if r != nil {
+				mt.Restrictions = append(mt.Restrictions, api.UsedRestriction{Restriction: *r})
+			}
+		}
+	}
+	label := mt.Name
+	if label == "" {
+		label = "the mytoken"
+	} else {
+		label = "'" + label + "'"
+	}
+
+	exp, clausesExpired := effectiveExpiry(mt, now.Unix())
+	if clausesExpired {
+		result.report(checkCritical, label+" cannot be used anymore, all restriction clauses expired")
+		return
+	}
+	checkExpiry(result, label, "expires_in", exp, now, thresholds)
+
+	if usagesKnown {
+		if remaining, limited := remainingUsages(mt, now.Unix(), false); limited {
+			msg := fmt.Sprintf("%s can be used for %d more access tokens", label, remaining)
+			switch {
+			case remaining <= thresholds.critUsagesAT:
+				result.report(checkCritical, msg)
+			case remaining <= thresholds.warnUsagesAT:
+				result.report(checkWarning, msg)
+			default:
+				result.report(checkOK, msg)
+			}
+			result.addPerfdata("usages_at_remaining", remaining, thresholds.warnUsagesAT, thresholds.critUsagesAT, "")
+		}
+	}
+
+	if checkOptions.All {
+		ownMOMID := ""
+		if introspection != nil {
+			ownMOMID = introspection.MOMID
+		}
+		checkAllMytokens(result, mytoken, mToken, ownMOMID, now, thresholds)
+	}
+}
+
+func checkExpiry(result *checkResult, label, perfLabel string, exp int64, now time.Time, t *checkThresholds) {
+	if exp == 0 {
+		result.report(checkOK, label+" does not expire")
+		return
+	}
+	left := time.Unix(exp, 0).Sub(now)
+	switch {
+	case left <= 0:
+		result.report(checkCritical, fmt.Sprintf("%s expired %s", label, timeexpr.Relative(exp, now)))
+	case left <= t.crit:
+		result.report(checkCritical, fmt.Sprintf("%s expires %s", label, timeexpr.Relative(exp, now)))
+	case left <= t.warn:
+		result.report(checkWarning, fmt.Sprintf("%s expires %s", label, timeexpr.Relative(exp, now)))
+	default:
+		result.report(checkOK, fmt.Sprintf("%s expires %s", label, timeexpr.Relative(exp, now)))
+	}
+	result.addPerfdata(perfLabel, int64(left/time.Second), int64(t.warn/time.Second), int64(t.crit/time.Second), "s")
+}
+
+// effectiveExpiry returns the time until which the mytoken can be used, i.e. the earlier of the expiration of the
+// mytoken and of the latest restriction clause; 0 means that it does not expire. The second return value is true if
+// the mytoken has restriction clauses, but all of them expired.
+func effectiveExpiry(mt api.UsedMytoken, now int64) (int64, bool) {
+	if len(mt.Restrictions) == 0 {
+		return mt.ExpiresAt, false
+	}
+	var clausesUntil int64
+	anyValid := false
+	for _, r := range mt.Restrictions {
+		if r.ExpiresAt != 0 && r.ExpiresAt < now {
+			continue
+		}
+		anyValid = true
+		if r.ExpiresAt == 0 {
+			clausesUntil = 0
+			break
+		}
+		if r.ExpiresAt > clausesUntil {
+			clausesUntil = r.ExpiresAt
+		}
+	}
+	if !anyValid {
+		return 0, true
+	}
+	if mt.ExpiresAt == 0 || (clausesUntil != 0 && clausesUntil < mt.ExpiresAt) {
+		return clausesUntil, false
+	}
+	return mt.ExpiresAt, false
+}
+
+// remainingUsages returns how many access tokens (or other usages, if other is set) can still be obtained with the
+// mytoken over all restriction clauses that did not expire; the second return value is false if the number is not
+// limited
+func remainingUsages(mt api.UsedMytoken, now int64, other bool) (int64, bool) {
+	if len(mt.Restrictions) == 0 {
+		return 0, false
+	}
+	var remaining int64
+	for _, r := range mt.Restrictions {
+		if r.ExpiresAt != 0 && r.ExpiresAt < now {
+			continue
+		}
+		usages, done := r.UsagesAT, r.UsagesATDone
+		if other {
+			usages, done = r.UsagesOther, r.UsagesOtherDone
+		}
+		if usages == nil {
+			return 0, false
+		}
+		left := *usages
+		if done != nil {
+			left -= *done
+		}
+		if left > 0 {
+			remaining += left
+		}
+	}
+	return remaining, true
+}
+
+// checkAllMytokens checks the other mytokens of the user; mytoken is the server of the used mytoken, which is nil
+// if it could not be reached or if ssh is used
+func checkAllMytokens(
+	result *checkResult, mytoken *mytokenlib.MytokenServer, mToken, ownMOMID string, now time.Time,
+	t *checkThresholds,
+) {
+	var tree []api.MytokenEntryTree
+	if ssh := checkOptions.SSH(); ssh != "" {
+		res, err := doSSHParseJSON[api.TokeninfoListResponse](ssh, api.SSHRequestTokenInfoListMytokens, nil)
+		if err != nil {
+			result.report(checkUnknown, fmt.Sprintf("could not list mytokens: %s", err))
+			return
+		}
+		tree = res.Tokens
+	} else {
+		if mytoken == nil {
+			result.report(checkUnknown, "could not list mytokens: the mytoken instance cannot be reached")
+			return
+		}
+		res, err := mytoken.Tokeninfo.APIListMytokens(mToken)
+		if err != nil {
+			result.report(checkUnknown, fmt.Sprintf("could not list mytokens: %s", err))
+			return
+		}
+		if res.TokenUpdate != nil {
+			updateMytoken(res.TokenUpdate.Mytoken)
+		}
+		tree = res.Tokens
+	}
+	checked := 0
+	var walk func(entries []api.MytokenEntryTree)
+	walk = func(entries []api.MytokenEntryTree) {
+		for _, e := range entries {
+			walk(e.Children)
+			tok := e.Token
+			if tok.MOMID == ownMOMID || tok.ExpiresAt == 0 || tok.ExpiresAt < now.Unix() {
+				continue
+			}
+			checked++
+			name := tok.Name
+			if name == "" {
+				name = tok.MOMID
+			}
+			sub := &checkResult{}
+			checkExpiry(sub, "'"+name+"'", "expires_in "+name, tok.ExpiresAt, now, t)
+			result.perfdata = append(result.perfdata, sub.perfdata...)
+			if sub.state != checkOK {
+				for _, p := range sub.problems {
+					result.report(sub.state, p)
+				}
+			}
+		}
+	}
+	walk(tree)
+	result.report(checkOK, fmt.Sprintf("%s of the list checked", numMytokens(checked)))
+}
+
+// mytokenClaims decodes the claims of a mytoken JWT
+func mytokenClaims(token string) (api.Mytoken, bool) {
+	var mt api.Mytoken
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return mt, false
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[1], "="))
+	if err != nil {
+		return mt, false
+	}
+	return mt, json.Unmarshal(payload, &mt) == nil
+}
@@ -0,0 +1,264 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/oidc-mytoken/api/v0"
+	mytokenlib "github.com/oidc-mytoken/lib"
+	log "github.com/sirupsen/logrus"
+	"github.com/urfave/cli/v3"
+
+	"github.com/oidc-mytoken/client/internal/config"
+	"github.com/oidc-mytoken/client/internal/store"
+	"github.com/oidc-mytoken/client/internal/utils/completioncache"
+	"github.com/oidc-mytoken/client/internal/utils/tokenfile"
+)
+
+// completeFunc returns the completion candidates for a flag value or an argument; candidates can have a description
+// separated by a colon
+type completeFunc func() []string
+
+// flagCompletions maps flag names to the functions completing their values
+var flagCompletions = map[string]completeFunc{
+	"provider": completeProviders,
+	"tags":     completeTags,
+	"mom-id":   completeMOMIDs,
+	"profile":  completeProfiles,
+}
+
+func init() {
+	app.EnableShellCompletion = true
+	app.ConfigureShellCompletionCommand = func(cmd *cli.Command) {
+		cmd.Hidden = false
+		cmd.Usage = "Output the shell completion script for bash, zsh, fish, or powershell"
+	}
+}
+
+// setupShellCompletion installs the dynamic shell completion on all commands that do not have their own completion
+func setupShellCompletion(cmd *cli.Command) {
+	if cmd.ShellComplete == nil {
+		cmd.ShellComplete = shellComplete(nil)
+	}
+	for _, c := range cmd.Commands {
+		setupShellCompletion(c)
+	}
+}
+
+// shellComplete returns a cli.ShellCompleteFunc that completes the values of flags listed in flagCompletions and,
+// if args is not nil, the arguments of the command
+func shellComplete(args completeFunc) cli.ShellCompleteFunc {
+	return func(ctx context.Context, cmd *cli.Command) {
+		tokenfile.DisablePrompt()
+		out := cmd.Root().Writer
+		var last string
+		if n := cmd.Args().Len(); n > 0 {
+			last = cmd.Args().Get(n - 1)
+		}
+		if strings.HasPrefix(last, "-") {
+			if complete := flagCompletion(cmd, strings.TrimLeft(last, "-")); complete != nil {
+				printCompletions(out, complete())
+				return
+			}
+		} else if args != nil {
+			printCompletions(out, args())
+			return
+		}
+		cli.DefaultCompleteWithFlags(ctx, cmd)
+	}
+}
+
+func flagCompletion(cmd *cli.Command, name string) completeFunc {
+	for _, f := range cmd.Flags {
+		if !slices.Contains(f.Names(), name) {
+			continue
+		}
+		if complete, ok := flagCompletions[f.Names()[0]]; ok {
+			return complete
+		}
+	}
+	return nil
+}
+
+func printCompletions(out interface{ Write([]byte) (int, error) }, candidates []string) {
+	for _, c := range candidates {
+		_, _ = fmt.Fprintln(out, c)
+	}
+}
+
+// cachedCompletions returns the cached completion candidates for the passed key parts or obtains them with get
+func cachedCompletions(get func() ([]string, error), keyParts ...string) []string {
+	key := completioncache.Key(keyParts...)
+	if values, ok := completioncache.Get(key); ok {
+		return values
+	}
+	values, err := get()
+	if err != nil {
+		log.WithError(err).Debug("could not obtain completion values")
+		return nil
+	}
+	if err = completioncache.Store(key, values); err != nil {
+		log.WithError(err).Debug("could not cache completion values")
+	}
+	return values
+}
+
+// completionMytoken returns the mytoken used for completions that need one and the url of the mytoken instance
+// that issued it; it never prompts
+func completionMytoken() (string, string) {
+	opts := MTOptions{}
+	if opts.MytokenPrompt() || opts.SSH() != "" {
+		return "", ""
+	}
+	mToken := opts._getToken()
+	return mToken, mytokenInstance(mToken)
+}
+
+// completionServer returns the mytoken server at the passed url; unlike config.Get().Mytoken() it does not exit if
+// the server cannot be reached, since a completion must never fail
+func completionServer(url string) (*mytokenlib.MytokenServer, error) {
+	return mytokenlib.NewMytokenServer(url)
+}
+
+// completeProviders completes the names of the providers from the config file and the issuer urls of the providers
+// supported by the mytoken server; as the completion scripts cannot handle colons, issuer urls are completed without
+// their scheme, which is also accepted by --provider
+func completeProviders() []string {
+	names := make([]string, 0, len(config.Get().Providers))
+	for n := range config.Get().Providers {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	issuers := cachedCompletions(
+		func() ([]string, error) {
+			mytoken, err := completionServer(config.Get().URL)
+			if err != nil {
+				return nil, err
+			}
+			var issuers []string
+			for _, p := range mytoken.ServerMetadata.ProvidersSupported {
+				c := issuerWithoutScheme(p.Issuer)
+				if p.Name != "" {
+					c += ":" + p.Name
+				}
+				issuers = append(issuers, c)
+			}
+			return issuers, nil
+		}, "providers", config.Get().URL,
+	)
+	return append(names, issuers...)
+}
+
+func completeTags() []string {
+	mToken, url := completionMytoken()
+	if mToken == "" {
+		return nil
+	}
+	return cachedCompletions(
+		func() ([]string, error) {
+			mytoken, err := completionServer(url)
+			if err != nil {
+				return nil, err
+			}
+			res, err := mytoken.UserSettings.Tags.APIGet(mToken)
+			if err != nil {
+				return nil, err
+			}
+			if res.TokenUpdate != nil {
+				updateMytoken(res.TokenUpdate.Mytoken)
+			}
+			tags := make([]string, len(res.Tags))
+			for i, t := range res.Tags {
+				tags[i] = string(t.Tag)
+			}
+			return tags, nil
+		}, "tags", url, mytokenID(mToken),
+	)
+}
+
+func completeMOMIDs() []string {
+	mToken, url := completionMytoken()
+	if mToken == "" {
+		return nil
+	}
+	return cachedCompletions(
+		func() ([]string, error) {
+			mytoken, err := completionServer(url)
+			if err != nil {
+				return nil, err
+			}
+			res, err := mytoken.Tokeninfo.APIListMytokens(mToken)
+			if err != nil {
+				return nil, err
+			}
+			if res.TokenUpdate != nil {
+				updateMytoken(res.TokenUpdate.Mytoken)
+			}
+			var momIDs []string
+			var collect func(tree []api.MytokenEntryTree)
+			collect = func(tree []api.MytokenEntryTree) {
+				for _, t := range tree {
+					c := t.Token.MOMID
+					if t.Token.Name != "" {
+						c += ":" + t.Token.Name
+					}
+					momIDs = append(momIDs, c)
+					collect(t.Children)
+				}
+			}
+			collect(res.Tokens)
+			return momIDs, nil
+		}, "mom-ids", url, mytokenID(mToken),
+	)
+}
+
+func completeProfiles() []string {
+	return cachedCompletions(
+		func() ([]string, error) {
+			mytoken, err := completionServer(config.Get().URL)
+			if err != nil {
+				return nil, err
+			}
+			pt := mytoken.ProfilesAndTemplates
+			groups, err := pt.APIGetGroups()
+			if err != nil {
+				return nil, err
+			}
+			var profiles []string
+			for _, g := range groups {
+				ps, err := pt.APIGetProfiles(g)
+				if err != nil {
+					return nil, err
+				}
+				for _, p := range ps {
+					profiles = append(profiles, g+"/"+p.Name)
+				}
+			}
+			return profiles, nil
+		}, "profiles", config.Get().URL,
+	)
+}
+
+func completeStoreNames() []string {
+	s, err := store.Load()
+	if err != nil {
+		return nil
+	}
+	names := make([]string, len(s.Entries))
+	for i, e := range s.Entries {
+		names[i] = e.Name
+	}
+	return names
+}
+
+func completeContextNames() []string {
+	names := make([]string, 0, len(config.Get().Contexts))
+	for n := range config.Get().Contexts {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -9,6 +9,7 @@ import (
 	"os"
 	"text/tabwriter"
 
+	"github.com/oidc-mytoken/utils/httpclient"
 	"github.com/urfave/cli/v3"
 
 	"github.com/oidc-mytoken/client/internal/config"
@@ -61,8 +62,27 @@ type CapabilityInfo struct {
 func getCapabilities(_ context.Context, _ *cli.Command) error {
 	mtServer := config.Get().Mytoken()
 
+	capabilities, err := fetchCapabilityTree(mtServer.ServerMetadata.Issuer)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tDESCRIPTION")
+	fmt.Fprintln(w, "----\t-----------")
+
+	printCapabilityTree(w, capabilities, "")
+
+	w.Flush()
+	return nil
+}
+
+// fetchCapabilityTree obtains the tree of capabilities from the mytoken server with the passed issuer url
+func fetchCapabilityTree(baseURL string) ([]CapabilityEntry, error) {
+	if baseURL == "" {
+		return nil, fmt.Errorf("no mytoken server url")
+	}
 	// Construct capabilities endpoint URL from server metadata
-	baseURL := mtServer.ServerMetadata.Issuer
 	var capsURL string
 	if baseURL[len(baseURL)-1] == '/' {
 		capsURL = baseURL + "api/v0/capabilities"
@@ -73,34 +93,25 @@ func getCapabilities(_ context.Context, _ *cli.Command) error {
 	// Make HTTP request to capabilities endpoint
 	req, err := http.NewRequest("GET", capsURL, http.NoBody)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	resp, err := httpclient.Do().GetClient().Do(req)
 	if err != nil {
-		return fmt.Errorf("failed to fetch capabilities: %w", err)
+		return nil, fmt.Errorf("failed to fetch capabilities: %w", err)
 	}
 	defer resp.Body.Close()
 
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	var capabilities []CapabilityEntry
 	if err := json.Unmarshal(body, &capabilities); err != nil {
-		return fmt.Errorf("failed to decode capabilities: %w", err)
+		return nil, fmt.Errorf("failed to decode capabilities: %w", err)
 	}
-
-	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
-	fmt.Fprintln(w, "NAME\tDESCRIPTION")
-	fmt.Fprintln(w, "----\t-----------")
-
-	printCapabilityTree(w, capabilities, "")
-
-	w.Flush()
-	return nil
+	return capabilities, nil
 }
 
 func printCapabilityTree(w *tabwriter.Writer, capabilities []CapabilityEntry, indent string) {
@@ -0,0 +1,173 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/urfave/cli/v3"
+	"gopkg.in/yaml.v3"
+
+	"github.com/oidc-mytoken/client/internal/config"
+	"github.com/oidc-mytoken/client/internal/utils/tablewriter"
+)
+
+func init() {
+	app.Commands = append(
+		app.Commands, &cli.Command{
+			Name: "context",
+			Aliases: []string{
+				"contexts",
+				"ctx",
+			},
+			Usage: "Manage the named contexts from the config file; " +
+				"a context bundles an instance with a default provider, default capabilities, " +
+				"a token name prefix and a default token source",
+			Commands: []*cli.Command{
+				{
+					Name:    "list",
+					Aliases: []string{"ls"},
+					Usage:   "List the contexts",
+					Action:  listContexts,
+				},
+				{
+					Name:          "use",
+					Usage:         "Make the context with the passed name the current one",
+					ArgsUsage:     "<name>",
+					Action:        useContext,
+					ShellComplete: shellComplete(completeContextNames),
+				},
+				{
+					Name:          "show",
+					Usage:         "Show the settings of a context",
+					ArgsUsage:     "[name]",
+					Action:        showContext,
+					ShellComplete: shellComplete(completeContextNames),
+				},
+			},
+		},
+	)
+}
+
+type tableContext struct {
+	name   string
+	ctx    *config.Context
+	active bool
+}
+
+func (tableContext) TableGetHeader() []string {
+	return []string{
+		"Current",
+		"Name",
+		"Instance",
+		"Default Provider",
+		"Token Source",
+	}
+}
+
+func (c tableContext) TableGetData() any {
+	return struct {
+		Current bool   `json:"current"`
+		Name    string `json:"name"`
+		*config.Context
+	}{
+		Current: c.active,
+		Name:    c.name,
+		Context: c.ctx,
+	}
+}
+
+func (c tableContext) TableGetRow() []string {
+	current := ""
+	if c.active {
+		current = "*"
+	}
+	return []string{
+		current,
+		c.name,
+		c.ctx.URL,
+		c.ctx.DefaultProvider,
+		tokenSourceString(c.ctx.TokenSource),
+	}
+}
+
+func tokenSourceString(src *config.TokenSource) string {
+	if src == nil {
+		return ""
+	}
+	var parts []string
+	if src.File != "" {
+		parts = append(parts, "file: "+src.File)
+	}
+	if src.Env != "" {
+		parts = append(parts, "env: "+src.Env)
+	}
+	if src.Name != "" {
+		parts = append(parts, "name: "+src.Name)
+	}
+	return strings.Join(parts, ", ")
+}
+
+func listContexts(_ context.Context, _ *cli.Command) error {
+	contexts := config.Get().Contexts
+	if len(contexts) == 0 {
+		fmt.Println("No contexts defined in the config file.")
+		return nil
+	}
+	names := make([]string, 0, len(contexts))
+	for n := range contexts {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	outputData := make([]tablewriter.TableWriter, len(names))
+	for i, n := range names {
+		ctx := contexts[n]
+		if ctx == nil {
+			ctx = &config.Context{}
+		}
+		outputData[i] = tableContext{
+			name:   n,
+			ctx:    ctx,
+			active: n == config.Get().ActiveContext(),
+		}
+	}
+	tablewriter.PrintTableData(outputData)
+	return nil
+}
+
+func useContext(_ context.Context, cmd *cli.Command) error {
+	if cmd.Args().Len() < 1 {
+		return fmt.Errorf("name required")
+	}
+	name := cmd.Args().Get(0)
+	if err := config.SetCurrentContext(name); err != nil {
+		return err
+	}
+	fmt.Printf("Now using context '%s'\n", name)
+	return nil
+}
+
+func showContext(_ context.Context, cmd *cli.Command) error {
+	name := config.Get().ActiveContext()
+	if cmd.Args().Len() > 0 {
+		name = cmd.Args().Get(0)
+	}
+	if name == "" {
+		return fmt.Errorf("no context in use; pass the name of a context")
+	}
+	ctx, ok := config.Get().Contexts[name]
+	if !ok {
+		return fmt.Errorf("context '%s' not found in config", name)
+	}
+	data, err := yaml.Marshal(
+		map[string]*config.Context{
+			name: ctx,
+		},
+	)
+	if err != nil {
+		return err
+	}
+	fmt.Print(string(data))
+	return nil
+}
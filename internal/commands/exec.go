@@ -0,0 +1,172 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/urfave/cli/v3"
+
+	cutils "github.com/oidc-mytoken/client/internal/utils"
+)
+
+var execCommand = struct {
+	MTOptions
+	Scopes    []string
+	Audiences []string
+	NoCache   bool
+	MinValid  time.Duration
+	Refresh   time.Duration
+}{}
+
+func init() {
+	stopAfterCommandName := 1
+	app.Commands = append(
+		app.Commands, &cli.Command{
+			Name: "exec",
+			Usage: "Run a command with an access token; the access token is exported in the BEARER_TOKEN " +
+				"environment variable and in a private file whose path is exported in BEARER_TOKEN_FILE",
+			ArgsUsage:    "[--] <command> [args...]",
+			Action:       runExec,
+			StopOnNthArg: &stopAfterCommandName,
+			Flags: appendMTFlags(
+				append(
+					getScopeAudienceFlags(&execCommand.Scopes, &execCommand.Audiences),
+					getNoCacheFlag(&execCommand.NoCache),
+					getMinValidFlag(&execCommand.MinValid),
+					&cli.DurationFlag{
+						Name: "refresh",
+						Usage: "Renew the access token in BEARER_TOKEN_FILE this `DURATION` before it expires, " +
+							"so long-running commands always find a valid token there; " +
+							"BEARER_TOKEN cannot be updated for a running command",
+						DefaultText: "no renewal",
+						Destination: &execCommand.Refresh,
+					},
+				)...,
+			),
+		},
+	)
+}
+
+func runExec(_ context.Context, cmd *cli.Command) error {
+	opts := execCommand
+	args := cmd.Args().Slice()
+	if len(args) > 0 && args[0] == "--" {
+		args = args[1:]
+	}
+	if len(args) == 0 {
+		return fmt.Errorf("command required")
+	}
+	fetch := accessTokenFetcher(opts.MTOptions, opts.Scopes, opts.Audiences, "exec", !opts.NoCache)
+	at, exp, err := fetch(opts.MinValid)
+	if err != nil {
+		return err
+	}
+
+	dir, err := os.MkdirTemp(os.Getenv("XDG_RUNTIME_DIR"), "mytoken-exec-")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := os.RemoveAll(dir); err != nil {
+			log.WithError(err).Error("could not remove bearer token file")
+		}
+	}()
+	tokenFile := filepath.Join(dir, "bearer_token")
+	if err = cutils.WriteFileAtomic(tokenFile, []byte(at), 0600); err != nil {
+		return err
+	}
+
+	c := exec.Command(args[0], args[1:]...)
+	c.Stdin = os.Stdin
+	c.Stdout = os.Stdout
+	c.Stderr = os.Stderr
+	c.Env = append(os.Environ(), "BEARER_TOKEN="+at, "BEARER_TOKEN_FILE="+tokenFile)
+
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, forwardedSignals...)
+	defer signal.Stop(sigs)
+	if err = c.Start(); err != nil {
+		return err
+	}
+	done := make(chan error, 1)
+	go func() {
+		done <- c.Wait()
+	}()
+
+	var refresh <-chan time.Time
+	var timer *time.Timer
+	if opts.Refresh > 0 && exp != 0 {
+		timer = time.NewTimer(refreshDelay(exp, opts.Refresh))
+		defer timer.Stop()
+		refresh = timer.C
+	}
+	var retry time.Duration
+	for {
+		select {
+		case sig := <-sigs:
+			if err = c.Process.Signal(sig); err != nil {
+				log.WithError(err).Error("could not forward signal")
+			}
+		case <-refresh:
+			// Only tokens that are valid for longer than the refresh duration are reused; the current token is not
+			at, exp, err = fetch(opts.Refresh)
+			if err == nil {
+				err = cutils.WriteFileAtomic(tokenFile, []byte(at), 0600)
+			}
+			if err != nil {
+				retry = nextRetryInterval(retry)
+				log.WithError(err).Errorf("could not renew access token, retrying in %s", retry)
+				timer.Reset(retry)
+				break
+			}
+			retry = 0
+			if exp == 0 {
+				log.Error("cannot renew the access token anymore, because its expiration time is unknown")
+				refresh = nil
+				break
+			}
+			timer.Reset(refreshDelay(exp, opts.Refresh))
+		case err = <-done:
+			return exitCodeError(err)
+		}
+	}
+}
+
+// refreshDelay returns the time until the access token expiring at exp must be renewed; it is at least
+// minWatchInterval, so an access token that is valid for less than the refresh duration is not renewed in a loop
+func refreshDelay(exp int64, refresh time.Duration) time.Duration {
+	return max(time.Until(time.Unix(exp, 0))-refresh, minWatchInterval)
+}
+
+// nextRetryInterval returns the time after which a failed renewal is retried; the interval is doubled for each
+// failed attempt, starting at watchRetryInterval
+func nextRetryInterval(last time.Duration) time.Duration {
+	if last <= 0 {
+		return watchRetryInterval
+	}
+	return min(2*last, maxRetryInterval)
+}
+
+// exitCodeError converts the result of running a command into an error that makes mytoken exit with the same code;
+// if the command was terminated by a signal, the exit code is 128 plus the signal number as in shells
+func exitCodeError(err error) error {
+	if err == nil {
+		return nil
+	}
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) {
+		return err
+	}
+	if status, ok := exitErr.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+		return cli.Exit("", 128+int(status.Signal()))
+	}
+	return cli.Exit("", exitErr.ExitCode())
+}
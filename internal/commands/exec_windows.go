@@ -0,0 +1,12 @@
+//go:build windows
+
+package commands
+
+import (
+	"os"
+)
+
+// forwardedSignals are the signals that mytoken exec forwards to the command
+var forwardedSignals = []os.Signal{
+	os.Interrupt,
+}
@@ -0,0 +1,92 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/urfave/cli/v3"
+
+	"github.com/oidc-mytoken/client/internal/utils/tokenfile"
+)
+
+var fileOptions = struct {
+	Method string
+	SSHKey string
+}{}
+
+func init() {
+	app.Commands = append(
+		app.Commands, &cli.Command{
+			Name:  "file",
+			Usage: "Encrypt and decrypt mytoken files",
+			Commands: []*cli.Command{
+				{
+					Name: "encrypt",
+					Usage: "Encrypt the mytoken in the passed file. " +
+						"The file can still be used with --MT-file and is re-encrypted if the mytoken is rotated",
+					ArgsUsage: "<file>",
+					Action:    encryptFile,
+					Flags: []cli.Flag{
+						&cli.StringFlag{
+							Name: "method",
+							Usage: fmt.Sprintf(
+								"The encryption `METHOD`; '%s' derives the key from a passphrase, "+
+									"'%s' from a signature of a key in your ssh-agent. "+
+									"The passphrase can also be passed in the %s environment variable.",
+								tokenfile.MethodPassphrase, tokenfile.MethodSSHAgent, tokenfile.PassphraseEnv,
+							),
+							Value:       tokenfile.MethodPassphrase,
+							Destination: &fileOptions.Method,
+						},
+						&cli.StringFlag{
+							Name: "ssh-key",
+							Usage: "The SHA256 `FINGERPRINT` of the ssh-agent key to use; " +
+								"must be an ed25519 or rsa key",
+							DefaultText: "first suitable key",
+							Destination: &fileOptions.SSHKey,
+						},
+					},
+				},
+				{
+					Name:      "decrypt",
+					Usage:     "Replace the encrypted mytoken in the passed file with the plain mytoken",
+					ArgsUsage: "<file>",
+					Action:    decryptFile,
+				},
+			},
+		},
+	)
+}
+
+func encryptFile(_ context.Context, cmd *cli.Command) error {
+	if cmd.Args().Len() < 1 {
+		return fmt.Errorf("file required")
+	}
+	file := cmd.Args().Get(0)
+	switch fileOptions.Method {
+	case tokenfile.MethodPassphrase, tokenfile.MethodSSHAgent:
+	default:
+		return fmt.Errorf("unknown encryption method '%s'", fileOptions.Method)
+	}
+	token, err := tokenfile.Read(file)
+	if err != nil {
+		return err
+	}
+	if err = tokenfile.Encrypt(file, token, fileOptions.Method, fileOptions.SSHKey); err != nil {
+		return err
+	}
+	fmt.Printf("Mytoken file '%s' encrypted\n", file)
+	return nil
+}
+
+func decryptFile(_ context.Context, cmd *cli.Command) error {
+	if cmd.Args().Len() < 1 {
+		return fmt.Errorf("file required")
+	}
+	file := cmd.Args().Get(0)
+	if err := tokenfile.Decrypt(file); err != nil {
+		return err
+	}
+	fmt.Printf("Mytoken file '%s' decrypted\n", file)
+	return nil
+}
@@ -3,7 +3,6 @@ package commands
 import (
 	"fmt"
 	"os"
-	"strings"
 
 	"github.com/Songmu/prompter"
 	"github.com/oidc-mytoken/api/v0"
@@ -15,6 +14,8 @@ import (
 	"github.com/urfave/cli/v3"
 
 	"github.com/oidc-mytoken/client/internal/config"
+	"github.com/oidc-mytoken/client/internal/store"
+	"github.com/oidc-mytoken/client/internal/utils/tokenfile"
 	"github.com/oidc-mytoken/client/internal/utils/wlcgtokendiscovery"
 )
 
@@ -23,6 +24,7 @@ type mtOptions struct {
 	MytokenPrompt bool
 	MytokenFile   string
 	MytokenEnv    string
+	MytokenName   string
 	SSH           string
 }
 
@@ -65,7 +67,7 @@ func (mt MTOptions) MytokenFile() string {
 
 func (MTOptions) SetMytokenFile(f string) {
 	if len(theMTOpts) == 0 {
-		theMTOpts = make([]*mtOptions, 1)
+		theMTOpts = []*mtOptions{{}}
 	}
 	theMTOpts[0].MytokenFile = f
 }
@@ -81,6 +83,24 @@ func (mt MTOptions) MytokenEnv() string {
 	return ""
 }
 
+func (mt MTOptions) MytokenName() string {
+	if res := mt.search(
+		func(options *mtOptions) interface{} {
+			return ternary.If(options.MytokenName != "", options.MytokenName, nil)
+		},
+	); res != nil {
+		return res.(string)
+	}
+	return ""
+}
+
+func (MTOptions) SetMytokenName(n string) {
+	if len(theMTOpts) == 0 {
+		theMTOpts = []*mtOptions{{}}
+	}
+	theMTOpts[0].MytokenName = n
+}
+
 func (mt MTOptions) SSH() string {
 	if res := mt.search(
 		func(options *mtOptions) interface{} {
@@ -118,7 +138,7 @@ func getMTFlags() []cli.Flag {
 		},
 		&cli.StringFlag{
 			Name:        "MT-file",
-			Usage:       "Read the mytoken that should be used from the first line of the passed `FILE`",
+			Usage:       "Read the mytoken that should be used from the first line of the passed `FILE`; encrypted files are decrypted",
 			TakesFile:   true,
 			Sources:     cli.NewValueSourceChain(cli.File("")),
 			Destination: &opts.MytokenFile,
@@ -128,6 +148,12 @@ func getMTFlags() []cli.Flag {
 			Usage:       "Read the mytoken that should be used from the passed environment variable `ENV`",
 			Destination: &opts.MytokenEnv,
 		},
+		&cli.StringFlag{
+			Name:        "MT-name",
+			Usage:       "Use the mytoken stored under `NAME` in the local mytoken store",
+			Sources:     cli.EnvVars("MYTOKEN_NAME"),
+			Destination: &opts.MytokenName,
+		},
 
 		&cli.StringFlag{
 			Name: "ssh",
@@ -189,40 +215,114 @@ func (mt MTOptions) _getToken() string {
 		}
 	}
 	if mt.MytokenFile() != "" {
-		content, err := os.ReadFile(mt.MytokenFile())
+		tok, err := tokenfile.Read(mt.MytokenFile())
 		if err != nil {
 			log.Fatal(err)
 		}
-		return strings.SplitN(string(content), "\n", 2)[0]
+		return tok
+	}
+	if mt.MytokenName() != "" {
+		return getTokenFromStore(mt.MytokenName())
+	}
+	if src := config.Get().TokenSource(); src != nil {
+		if tok := mt.getTokenFromSource(src); tok != "" {
+			return tok
+		}
 	}
 	if config.Get().UseWLCGTokenDiscovery {
-		t, f := wlcgtokendiscovery.FindToken()
-		mt.SetMytokenFile(f)
-		return t
+		if t, f := wlcgtokendiscovery.FindToken(); t != "" {
+			mt.SetMytokenFile(f)
+			return t
+		}
+	}
+	// the current mytoken of the store is only used if no mytoken was found otherwise, so a mytoken found through
+	// the token discovery still takes precedence
+	if s, err := store.Load(); err == nil && s.Current != "" {
+		mt.SetMytokenName(s.Current)
+		return getTokenFromStore(s.Current)
 	}
 	return ""
 }
 
-func updateMytoken(updatedToken string) {
-	f := MTOptions{}.MytokenFile()
-	if f == "" {
-		_, err := fmt.Fprintf(
-			os.Stderr, "The used mytoken changed ("+
-				"this indicates that token rotation is enabled for it), "+
-				"but the updated mytoken cannot be stored back, because it was not passed in a file. "+
-				"This is the updated mytoken:\n%s\n\n", updatedToken,
-		)
+// getTokenFromSource returns the mytoken from the default token source of a context
+func (mt MTOptions) getTokenFromSource(src *config.TokenSource) string {
+	switch {
+	case src.File != "":
+		mt.SetMytokenFile(src.File)
+		tok, err := tokenfile.Read(src.File)
 		if err != nil {
-			log.Error(err)
+			log.Fatal(err)
 		}
+		return tok
+	case src.Env != "":
+		return os.Getenv(src.Env)
+	case src.Name != "":
+		mt.SetMytokenName(src.Name)
+		return getTokenFromStore(src.Name)
+	}
+	return ""
+}
+
+func getTokenFromStore(name string) string {
+	s, err := store.Load()
+	if err != nil {
+		log.Fatal(err)
+	}
+	e := s.Get(name)
+	if e == nil {
+		log.Fatalf("No mytoken with name '%s' in the mytoken store.", name)
+	}
+	if !jwtutils.IsJWT(e.Mytoken) && e.Issuer != "" && !issuerutils.CompareIssuerURLs(config.Get().URL, e.Issuer) {
+		config.SetURL(e.Issuer)
+	}
+	return e.Mytoken
+}
+
+func updateMytoken(updatedToken string) {
+	opts := MTOptions{}
+	stored, err := storeUpdatedMytoken(opts.MytokenFile(), opts.MytokenName(), updatedToken)
+	if err != nil {
+		log.Error(err)
+	}
+	if stored {
 		return
 	}
-	err := os.WriteFile(f, []byte(updatedToken), 0600)
+	_, err = fmt.Fprintf(
+		os.Stderr, "The used mytoken changed ("+
+			"this indicates that token rotation is enabled for it), "+
+			"but the updated mytoken cannot be stored back, because it was neither passed in a file nor taken "+
+			"from the mytoken store. This is the updated mytoken:\n%s\n\n", updatedToken,
+	)
 	if err != nil {
 		log.Error(err)
 	}
 }
 
+// storeUpdatedMytoken writes an updated mytoken back to the file or the store entry it was read from;
+// it returns false if there is neither a file nor a store entry
+func storeUpdatedMytoken(file, storeName, updatedToken string) (bool, error) {
+	if file != "" {
+		return true, tokenfile.Write(file, updatedToken)
+	}
+	if storeName != "" {
+		return true, store.UpdateMytoken(storeName, updatedToken)
+	}
+	return false, nil
+}
+
+// mytokenServerForToken returns the mytoken server that issued the passed token; if this cannot be determined from
+// the token, the configured server is returned
+func mytokenServerForToken(token string) (*mytokenlib.MytokenServer, error) {
+	if !jwtutils.IsJWT(token) {
+		return config.Get().Mytoken(), nil
+	}
+	iss, ok := jwtutils.GetStringFromJWT(log.StandardLogger(), token, "iss")
+	if !ok || issuerutils.CompareIssuerURLs(config.Get().URL, iss) {
+		return config.Get().Mytoken(), nil
+	}
+	return mytokenlib.NewMytokenServer(iss)
+}
+
 func findCommand(commands []*cli.Command, name string) *cli.Command {
 	for _, c := range commands {
 		if c.Name == name {
@@ -1,24 +1,33 @@
 package commands
 
 import (
-	"bytes"
 	"context"
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
+	"os"
+	"sort"
 	"strings"
 	"time"
 
 	"github.com/oidc-mytoken/api/v0"
 	"github.com/oidc-mytoken/utils/utils/jwtutils"
+	log "github.com/sirupsen/logrus"
 	"github.com/urfave/cli/v3"
 
 	"github.com/oidc-mytoken/client/internal/config"
 	"github.com/oidc-mytoken/client/internal/utils/color"
+	"github.com/oidc-mytoken/client/internal/utils/jwks"
 	"github.com/oidc-mytoken/client/internal/utils/tablewriter"
 )
 
 var infoOptions MTOptions
+var infoVerify bool
+
+var listMytokensOptions struct {
+	mytokenSelectorOptions
+	SortBy string
+}
 
 var infoNotificationsOptions = struct {
 	MTOptions
@@ -34,13 +43,21 @@ func init() {
 			Aliases: []string{"tokeninfo"},
 			Usage:   "Get information about a mytoken",
 			Action:  info,
-			Flags:   cmdFlags,
+			Flags: append(
+				cmdFlags,
+				&cli.BoolFlag{
+					Name: "verify",
+					Usage: "Verify the signature of the mytoken with the keys of the mytoken instance; " +
+						"the keys are cached locally, so later verifications also work offline",
+					Destination: &infoVerify,
+				},
+			),
 			Commands: []*cli.Command{
 				{
 					Name:   "history",
 					Usage:  "List the event history for this token",
 					Action: history,
-					Flags:  subCmdFlags,
+					Flags:  append(subCmdFlags, getHistoryFlags()...),
 				},
 				{
 					Name: "subtokens",
@@ -50,7 +67,18 @@ func init() {
 					},
 					Usage:  "List the tree of subtokens for this token",
 					Action: subTree,
-					Flags:  subCmdFlags,
+					Flags: append(
+						subCmdFlags,
+						&cli.BoolFlag{
+							Name:  "include-mom-id",
+							Usage: "Include the MOM-ID column in the output",
+						},
+						&cli.StringFlag{
+							Name: "format",
+							Usage: "Export the delegation graph in the given `FORMAT` instead of listing it; " +
+								"one of dot (graphviz) and mermaid. Expired tokens are greyed out.",
+						},
+					),
 				},
 				{
 					Name:   "introspect",
@@ -64,11 +92,20 @@ func init() {
 					Usage:   "List all mytokens",
 					Action:  listMytokens,
 					Flags: append(
-						subCmdFlags,
-						&cli.BoolFlag{
-							Name:  "include-mom-id",
-							Usage: "Include the MOM-ID column in the output",
-						},
+						append(
+							subCmdFlags,
+							&cli.BoolFlag{
+								Name:  "include-mom-id",
+								Usage: "Include the MOM-ID column in the output",
+							},
+							&cli.StringFlag{
+								Name: "sort-by",
+								Usage: "Sort mytokens with the same parent by `FIELD`; one of created, expires, " +
+									"and name",
+								Destination: &listMytokensOptions.SortBy,
+							},
+						),
+						getMytokenSelectorFlags(&listMytokensOptions.mytokenSelectorOptions)...,
 					),
 				},
 				{
@@ -90,35 +127,24 @@ func init() {
 }
 
 func prettyPrintJSONString(str string) error {
-	var data interface{}
-	if err := json.Unmarshal([]byte(str), &data); err != nil {
+	if !json.Valid([]byte(str)) {
 		return fmt.Errorf("%s", str)
 	}
-	return prettyPrintJSON(data)
+	return prettyPrintJSON([]byte(str))
 }
 
+// prettyPrintJSON prints the passed object, or the passed json if a []byte is passed, in the selected output format
 func prettyPrintJSON(obj interface{}) error {
-	var data []byte
-	switch v := obj.(type) {
-	case []byte:
-		data = v
-	default:
-		tmp, err := json.Marshal(obj)
-		if err != nil {
-			return fmt.Errorf("Internal error: %s", err)
-		}
-		data = tmp
-	}
-	var infoBuffer bytes.Buffer
-	if err := json.Indent(&infoBuffer, data, "", "  "); err != nil {
-		return err
-	}
-	fmt.Println(infoBuffer.String())
-	return nil
+	return tablewriter.PrintData(obj)
 }
 
 func info(_ context.Context, _ *cli.Command) error {
-	mToken := infoOptions.MustGetToken()
+	// the token is not obtained with MustGetToken, which contacts the instance named in the unverified token
+	configuredURL := config.Get().URL
+	mToken := infoOptions._getToken()
+	if mToken == "" {
+		return fmt.Errorf("No mytoken provided.")
+	}
 	if !jwtutils.IsJWT(mToken) {
 		return fmt.Errorf("The token is not a JWT.")
 	}
@@ -127,7 +153,25 @@ func info(_ context.Context, _ *cli.Command) error {
 	if err != nil {
 		return err
 	}
-	return prettyPrintJSON(decodedPayload)
+	var mt api.Mytoken
+	if err = json.Unmarshal(decodedPayload, &mt); err != nil {
+		return err
+	}
+	var verification *jwks.Result
+	if infoVerify {
+		if err = checkTrustedMytokenIssuer(mt.Issuer, configuredURL); err != nil {
+			return fmt.Errorf("signature verification failed: %w", err)
+		}
+		verification, err = verifyMytokenSignature(mToken, mt.Issuer)
+		if err != nil {
+			return fmt.Errorf("signature verification failed: %w", err)
+		}
+	}
+	if tablewriter.MachineReadable() {
+		return prettyPrintJSON(decodedPayload)
+	}
+	printMytokenDetails(os.Stdout, mToken, mt, trustedIssuer(mt.Issuer, configuredURL), verification)
+	return nil
 }
 
 func introspect(_ context.Context, _ *cli.Command) error {
@@ -147,34 +191,6 @@ func introspect(_ context.Context, _ *cli.Command) error {
 	return prettyPrintJSON(res)
 }
 
-func history(_ context.Context, _ *cli.Command) error {
-	var res api.TokeninfoHistoryResponse
-	if ssh := infoOptions.SSH(); ssh != "" {
-		pRes, err := doSSHParseJSON[api.TokeninfoHistoryResponse](ssh, api.SSHRequestTokenInfoHistory, nil)
-		if err != nil {
-			return err
-		}
-		res = *pRes
-	} else {
-		mToken := infoOptions.MustGetToken()
-		mytoken := config.Get().Mytoken()
-		var err error
-		res, err = mytoken.Tokeninfo.APIHistory(mToken)
-		if err != nil {
-			return err
-		}
-		if res.TokenUpdate != nil {
-			updateMytoken(res.TokenUpdate.Mytoken)
-		}
-	}
-	outputData := make([]tablewriter.TableWriter, len(res.EventHistory.Events))
-	for i, d := range res.EventHistory.Events {
-		outputData[i] = tableEventEntry(d)
-	}
-	tablewriter.PrintTableData(outputData)
-	return nil
-}
-
 type tableEventEntry api.EventEntry
 
 func (tableEventEntry) TableGetHeader() []string {
@@ -197,7 +213,11 @@ func (e tableEventEntry) TableGetRow() []string {
 	}
 }
 
-func subTree(_ context.Context, _ *cli.Command) error {
+func subTree(_ context.Context, cmd *cli.Command) error {
+	graphFormat := strings.ToLower(cmd.String("format"))
+	if graphFormat != "" && graphFormat != graphFormatDOT && graphFormat != graphFormatMermaid {
+		return fmt.Errorf("unknown graph format '%s'; use %s or %s", graphFormat, graphFormatDOT, graphFormatMermaid)
+	}
 	var res api.TokeninfoSubtokensResponse
 	if ssh := infoOptions.SSH(); ssh != "" {
 		pRes, err := doSSHParseJSON[api.TokeninfoSubtokensResponse](ssh, api.SSHRequestTokenInfoSubtokens, nil)
@@ -217,10 +237,28 @@ func subTree(_ context.Context, _ *cli.Command) error {
 			updateMytoken(res.TokenUpdate.Mytoken)
 		}
 	}
-	return prettyPrintJSON(res.Tokens)
+	tree := []api.MytokenEntryTree{res.Tokens}
+	switch graphFormat {
+	case graphFormatDOT:
+		return writeMytokenGraphDOT(os.Stdout, tree)
+	case graphFormatMermaid:
+		return writeMytokenGraphMermaid(os.Stdout, tree)
+	}
+	if tablewriter.Structured() {
+		return tablewriter.PrintData(res.Tokens)
+	}
+	tablewriter.PrintTableData(flattenMytokenEntryTree(tree, cmd.Bool("include-mom-id"), nil))
+	return nil
 }
 
 func listMytokens(_ context.Context, cmd *cli.Command) error {
+	selector, err := listMytokensOptions.selector()
+	if err != nil {
+		return err
+	}
+	if err = checkMytokenSortField(listMytokensOptions.SortBy); err != nil {
+		return err
+	}
 	var res api.TokeninfoListResponse
 	if ssh := infoOptions.SSH(); ssh != "" {
 		pRes, err := doSSHParseJSON[api.TokeninfoListResponse](ssh, api.SSHRequestTokenInfoListMytokens, nil)
@@ -231,7 +269,6 @@ func listMytokens(_ context.Context, cmd *cli.Command) error {
 	} else {
 		mToken := infoOptions.MustGetToken()
 		mytoken := config.Get().Mytoken()
-		var err error
 		res, err = mytoken.Tokeninfo.APIListMytokens(mToken)
 		if err != nil {
 			return err
@@ -240,40 +277,90 @@ func listMytokens(_ context.Context, cmd *cli.Command) error {
 			updateMytoken(res.TokenUpdate.Mytoken)
 		}
 	}
+	tree, parents := selector.filterMytokenTree(res.Tokens)
+	sortMytokenTree(tree, listMytokensOptions.SortBy)
+	if tablewriter.Structured() {
+		return tablewriter.PrintData(tree)
+	}
 	includeMOMID := cmd.Bool("include-mom-id")
-	outputData := flattenMytokenEntryTree(res.Tokens, includeMOMID)
+	outputData := flattenMytokenEntryTree(tree, includeMOMID, parents)
 	tablewriter.PrintTableData(outputData)
 	return nil
 }
 
-func flattenMytokenEntryTree(tree []api.MytokenEntryTree, includeMOMID bool) []tablewriter.TableWriter {
-	var result []tablewriter.TableWriter
-	for _, entry := range tree {
-		result = append(
-			result, tableMytokenEntry{
-				entry:        entry.Token,
-				depth:        0,
-				includeMOMID: includeMOMID,
-			},
+const (
+	mytokenSortCreated = "created"
+	mytokenSortExpires = "expires"
+	mytokenSortName    = "name"
+)
+
+func checkMytokenSortField(field string) error {
+	switch field {
+	case "", mytokenSortCreated, mytokenSortExpires, mytokenSortName:
+		return nil
+	default:
+		return fmt.Errorf(
+			"cannot sort by '%s'; use %s, %s, or %s", field, mytokenSortCreated, mytokenSortExpires, mytokenSortName,
 		)
-		result = append(result, flattenMytokenEntryTreeRecursive(entry.Children, 1, includeMOMID)...)
 	}
-	return result
+}
+
+// sortMytokenTree sorts mytokens with the same parent in place; mytokens that do not expire are sorted last when
+// sorting by expiration
+func sortMytokenTree(tree []api.MytokenEntryTree, field string) {
+	if field == "" {
+		return
+	}
+	less := func(a, b api.MytokenEntry) bool {
+		switch field {
+		case mytokenSortExpires:
+			if a.ExpiresAt == 0 || b.ExpiresAt == 0 {
+				return a.ExpiresAt != 0 && b.ExpiresAt == 0
+			}
+			return a.ExpiresAt < b.ExpiresAt
+		case mytokenSortName:
+			return strings.ToLower(a.Name) < strings.ToLower(b.Name)
+		default:
+			return a.CreatedAt < b.CreatedAt
+		}
+	}
+	sort.SliceStable(
+		tree, func(i, j int) bool {
+			return less(tree[i].Token, tree[j].Token)
+		},
+	)
+	for _, e := range tree {
+		sortMytokenTree(e.Children, field)
+	}
+}
+
+// flattenMytokenEntryTree flattens a mytoken tree for table output; mytokens whose MOM-ID is in parents are only
+// shown to preserve the hierarchy; they are dimmed in tables and omitted from csv output
+func flattenMytokenEntryTree(
+	tree []api.MytokenEntryTree, includeMOMID bool, parents map[string]bool,
+) []tablewriter.TableWriter {
+	return flattenMytokenEntryTreeRecursive(tree, 0, includeMOMID, parents)
 }
 
 func flattenMytokenEntryTreeRecursive(
-	tree []api.MytokenEntryTree, depth int, includeMOMID bool,
+	tree []api.MytokenEntryTree, depth int, includeMOMID bool, parents map[string]bool,
 ) []tablewriter.TableWriter {
 	var result []tablewriter.TableWriter
 	for _, entry := range tree {
+		dimmed := parents[entry.Token.MOMID]
+		if !dimmed || !tablewriter.MachineReadable() {
+			result = append(
+				result, tableMytokenEntry{
+					entry:        entry.Token,
+					depth:        depth,
+					includeMOMID: includeMOMID,
+					dimmed:       dimmed,
+				},
+			)
+		}
 		result = append(
-			result, tableMytokenEntry{
-				entry:        entry.Token,
-				depth:        depth,
-				includeMOMID: includeMOMID,
-			},
+			result, flattenMytokenEntryTreeRecursive(entry.Children, depth+1, includeMOMID, parents)...,
 		)
-		result = append(result, flattenMytokenEntryTreeRecursive(entry.Children, depth+1, includeMOMID)...)
 	}
 	return result
 }
@@ -282,6 +369,8 @@ type tableMytokenEntry struct {
 	entry        api.MytokenEntry
 	depth        int
 	includeMOMID bool
+	// dimmed is set for mytokens that are only shown to preserve the hierarchy
+	dimmed bool
 }
 
 func (e tableMytokenEntry) TableGetHeader() []string {
@@ -304,6 +393,10 @@ func (e tableMytokenEntry) TableGetHeader() []string {
 	}
 }
 
+func (e tableMytokenEntry) TableGetData() any {
+	return e.entry
+}
+
 func (e tableMytokenEntry) TableGetRow() []string {
 	const timeFmt = "2006-01-02 15:04:05"
 	now := time.Now().Unix()
@@ -331,7 +424,7 @@ func (e tableMytokenEntry) TableGetRow() []string {
 	if name == "" {
 		name = color.Italic("unnamed token")
 	}
-	if e.depth > 0 {
+	if e.depth > 0 && !tablewriter.MachineReadable() {
 		name = strings.Repeat("  ", e.depth) + "└─ " + name
 	}
 
@@ -341,6 +434,12 @@ func (e tableMytokenEntry) TableGetRow() []string {
 		expires = color.Gray(expires)
 		tags = color.Gray(tags)
 	}
+	if e.dimmed {
+		name = color.Dim(name)
+		created = color.Dim(created)
+		expires = color.Dim(expires)
+		tags = color.Dim(tags)
+	}
 
 	if e.includeMOMID {
 		return []string{
@@ -396,6 +495,20 @@ func infoNotifications(_ context.Context, _ *cli.Command) (err error) {
 }
 
 func renderNotificationsCalendars(notifications []api.NotificationInfo, calendars []api.CalendarInfo) {
+	if tablewriter.Structured() {
+		if err := tablewriter.PrintData(
+			struct {
+				Notifications []api.NotificationInfo `json:"notifications"`
+				Calendars     []api.CalendarInfo     `json:"calendars"`
+			}{
+				Notifications: notifications,
+				Calendars:     calendars,
+			},
+		); err != nil {
+			log.WithError(err).Error("could not print output")
+		}
+		return
+	}
 	if len(notifications) > 0 {
 		fmt.Println("Notifications:")
 		outputData := make([]tablewriter.TableWriter, len(notifications))
@@ -0,0 +1,128 @@
+package commands
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/oidc-mytoken/api/v0"
+)
+
+const (
+	graphFormatDOT     = "dot"
+	graphFormatMermaid = "mermaid"
+)
+
+// mytokenGraphNode is a mytoken in a delegation graph
+type mytokenGraphNode struct {
+	id      string
+	parent  string
+	label   []string
+	expired bool
+}
+
+// mytokenGraphNodes flattens a mytoken tree into graph nodes; nodes are identified by their position, since MOM-IDs
+// are not valid identifiers in all graph languages
+func mytokenGraphNodes(tree []api.MytokenEntryTree) []mytokenGraphNode {
+	const timeFmt = "2006-01-02 15:04"
+	now := time.Now().Unix()
+	var nodes []mytokenGraphNode
+	var walk func(entries []api.MytokenEntryTree, parent string)
+	walk = func(entries []api.MytokenEntryTree, parent string) {
+		for _, e := range entries {
+			t := e.Token
+			n := mytokenGraphNode{
+				id:      "t" + strconv.Itoa(len(nodes)),
+				parent:  parent,
+				expired: t.ExpiresAt > 0 && t.ExpiresAt < now,
+			}
+			name := t.Name
+			if name == "" {
+				name = "unnamed token"
+			}
+			n.label = append(n.label, name)
+			if t.MOMID != "" {
+				n.label = append(n.label, t.MOMID)
+			}
+			n.label = append(n.label, "created "+time.Unix(t.CreatedAt, 0).Format(timeFmt))
+			switch {
+			case t.ExpiresAt == 0:
+				n.label = append(n.label, "does not expire")
+			case n.expired:
+				n.label = append(n.label, "expired "+time.Unix(t.ExpiresAt, 0).Format(timeFmt))
+			default:
+				n.label = append(n.label, "expires "+time.Unix(t.ExpiresAt, 0).Format(timeFmt))
+			}
+			if len(t.Tags) > 0 {
+				tags := make([]string, len(t.Tags))
+				for i, tag := range t.Tags {
+					tags[i] = string(tag.Tag)
+				}
+				n.label = append(n.label, "tags: "+strings.Join(tags, ", "))
+			}
+			nodes = append(nodes, n)
+			walk(e.Children, n.id)
+		}
+	}
+	walk(tree, "")
+	return nodes
+}
+
+// writeMytokenGraphDOT writes the delegation graph of a mytoken tree in the graphviz dot language
+func writeMytokenGraphDOT(w io.Writer, tree []api.MytokenEntryTree) error {
+	var b strings.Builder
+	b.WriteString("digraph mytokens {\n")
+	b.WriteString("  rankdir=TB;\n")
+	b.WriteString("  node [shape=box, style=rounded, fontname=\"sans-serif\"];\n")
+	nodes := mytokenGraphNodes(tree)
+	for _, n := range nodes {
+		escaped := make([]string, len(n.label))
+		for i, l := range n.label {
+			escaped[i] = strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(l)
+		}
+		attrs := fmt.Sprintf("label=\"%s\"", strings.Join(escaped, `\n`))
+		if n.expired {
+			attrs += ", color=gray, fontcolor=gray, style=\"rounded,dashed\""
+		}
+		_, _ = fmt.Fprintf(&b, "  %s [%s];\n", n.id, attrs)
+	}
+	for _, n := range nodes {
+		if n.parent != "" {
+			_, _ = fmt.Fprintf(&b, "  %s -> %s;\n", n.parent, n.id)
+		}
+	}
+	b.WriteString("}\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// writeMytokenGraphMermaid writes the delegation graph of a mytoken tree as a mermaid flowchart
+func writeMytokenGraphMermaid(w io.Writer, tree []api.MytokenEntryTree) error {
+	var b strings.Builder
+	b.WriteString("flowchart TD\n")
+	b.WriteString("  classDef expired fill:#eeeeee,stroke:#999999,color:#999999,stroke-dasharray:4 2\n")
+	nodes := mytokenGraphNodes(tree)
+	var expired []string
+	for _, n := range nodes {
+		escaped := make([]string, len(n.label))
+		for i, l := range n.label {
+			escaped[i] = strings.NewReplacer(`"`, "#quot;", "<", "#lt;", ">", "#gt;").Replace(l)
+		}
+		_, _ = fmt.Fprintf(&b, "  %s[\"%s\"]\n", n.id, strings.Join(escaped, "<br/>"))
+		if n.expired {
+			expired = append(expired, n.id)
+		}
+	}
+	for _, n := range nodes {
+		if n.parent != "" {
+			_, _ = fmt.Fprintf(&b, "  %s --> %s\n", n.parent, n.id)
+		}
+	}
+	if len(expired) > 0 {
+		_, _ = fmt.Fprintf(&b, "  class %s expired\n", strings.Join(expired, ","))
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
@@ -0,0 +1,438 @@
+package commands
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/oidc-mytoken/api/v0"
+	"github.com/pkg/errors"
+	"github.com/urfave/cli/v3"
+
+	"github.com/oidc-mytoken/client/internal/config"
+	"github.com/oidc-mytoken/client/internal/utils/tablewriter"
+	"github.com/oidc-mytoken/client/internal/utils/timeexpr"
+)
+
+var historyOptions struct {
+	Events    []string
+	Since     string
+	Until     string
+	IPs       []string
+	UserAgent string
+	Comment   string
+	Follow    bool
+	Interval  time.Duration
+}
+
+func getHistoryFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.StringSliceFlag{
+			Name:        "event",
+			Usage:       "Only list events of this `TYPE`, e.g. AT_created; can be used multiple times",
+			Destination: &historyOptions.Events,
+		},
+		&cli.StringFlag{
+			Name:        "since",
+			Usage:       "Only list events at or after `TIME`. " + timeexpr.PastHelp,
+			Destination: &historyOptions.Since,
+		},
+		&cli.StringFlag{
+			Name:        "until",
+			Usage:       "Only list events at or before `TIME`. " + timeexpr.PastHelp,
+			Destination: &historyOptions.Until,
+		},
+		&cli.StringSliceFlag{
+			Name:        "ip",
+			Usage:       "Only list events from this `IP` address or network (CIDR); can be used multiple times",
+			Destination: &historyOptions.IPs,
+		},
+		&cli.StringFlag{
+			Name:        "user-agent",
+			Usage:       "Only list events whose user agent contains `TEXT` (case-insensitive)",
+			Destination: &historyOptions.UserAgent,
+		},
+		&cli.StringFlag{
+			Name:        "comment",
+			Usage:       "Only list events whose comment matches the regular expression `REGEX`",
+			Destination: &historyOptions.Comment,
+		},
+		&cli.BoolFlag{
+			Name:        "follow",
+			Aliases:     []string{"f"},
+			Usage:       "Keep polling the event history and print new events as they occur",
+			Destination: &historyOptions.Follow,
+		},
+		&cli.DurationFlag{
+			Name:        "interval",
+			Usage:       "Poll the event history every `DURATION` when following",
+			Value:       10 * time.Second,
+			Destination: &historyOptions.Interval,
+		},
+	}
+}
+
+type historyFilter struct {
+	events    map[string]bool
+	since     int64
+	until     int64
+	ips       *ipMatcher
+	userAgent string
+	comment   *regexp.Regexp
+}
+
+func newHistoryFilter() (*historyFilter, error) {
+	now := time.Now()
+	f := &historyFilter{
+		userAgent: strings.ToLower(historyOptions.UserAgent),
+	}
+	if len(historyOptions.Events) > 0 {
+		f.events = make(map[string]bool, len(historyOptions.Events))
+		for _, e := range historyOptions.Events {
+			f.events[strings.ToLower(strings.TrimSpace(e))] = true
+		}
+	}
+	var err error
+	if f.since, err = timeexpr.ParsePast(historyOptions.Since, now); err != nil {
+		return nil, errors.Wrap(err, "invalid value for --since")
+	}
+	if f.until, err = timeexpr.ParsePast(historyOptions.Until, now); err != nil {
+		return nil, errors.Wrap(err, "invalid value for --until")
+	}
+	if f.since != 0 && f.until != 0 && f.since > f.until {
+		return nil, errors.New("--since must not be after --until")
+	}
+	if len(historyOptions.IPs) > 0 {
+		if f.ips, err = newIPMatcher(historyOptions.IPs); err != nil {
+			return nil, err
+		}
+	}
+	if historyOptions.Comment != "" {
+		if f.comment, err = regexp.Compile(historyOptions.Comment); err != nil {
+			return nil, errors.Wrap(err, "invalid value for --comment")
+		}
+	}
+	return f, nil
+}
+
+func (f *historyFilter) match(e api.EventEntry) bool {
+	if f.events != nil && !f.events[strings.ToLower(string(e.Event))] {
+		return false
+	}
+	if f.since != 0 && e.Time < f.since {
+		return false
+	}
+	if f.until != 0 && e.Time > f.until {
+		return false
+	}
+	if f.ips != nil && !f.ips.match(e.IP) {
+		return false
+	}
+	if f.userAgent != "" && !strings.Contains(strings.ToLower(e.UserAgent), f.userAgent) {
+		return false
+	}
+	if f.comment != nil && !f.comment.MatchString(e.Comment) {
+		return false
+	}
+	return true
+}
+
+func (f *historyFilter) apply(events []api.EventEntry) []api.EventEntry {
+	var matching []api.EventEntry
+	for _, e := range events {
+		if f.match(e) {
+			matching = append(matching, e)
+		}
+	}
+	return matching
+}
+
+// fetchHistory obtains the event history; it returns the mytoken that must be used for the next request, which
+// differs from the passed one if the mytoken was rotated
+func fetchHistory(ssh, mToken string) ([]api.EventEntry, string, error) {
+	if ssh != "" {
+		res, err := doSSHParseJSON[api.TokeninfoHistoryResponse](ssh, api.SSHRequestTokenInfoHistory, nil)
+		if err != nil {
+			return nil, mToken, err
+		}
+		return res.Events, mToken, nil
+	}
+	res, err := config.Get().Mytoken().Tokeninfo.APIHistory(mToken)
+	if err != nil {
+		return nil, mToken, err
+	}
+	if res.TokenUpdate != nil {
+		updateMytoken(res.TokenUpdate.Mytoken)
+		mToken = res.TokenUpdate.Mytoken
+	}
+	return res.Events, mToken, nil
+}
+
+func history(ctx context.Context, _ *cli.Command) error {
+	filter, err := newHistoryFilter()
+	if err != nil {
+		return err
+	}
+	ssh := infoOptions.SSH()
+	var mToken string
+	if ssh == "" {
+		mToken = infoOptions.MustGetToken()
+	}
+	if historyOptions.Follow {
+		return followHistory(ctx, filter, ssh, mToken)
+	}
+	events, _, err := fetchHistory(ssh, mToken)
+	if err != nil {
+		return err
+	}
+	events = filter.apply(events)
+	summary := summarizeHistory(events)
+	switch tablewriter.Format() {
+	case tablewriter.FormatJSON, tablewriter.FormatYAML:
+		return tablewriter.PrintData(
+			struct {
+				Events  []api.EventEntry `json:"events"`
+				Summary historySummary   `json:"summary"`
+			}{
+				Events:  events,
+				Summary: summary,
+			},
+		)
+	case tablewriter.FormatTable:
+		if len(events) == 0 {
+			fmt.Println("No events found.")
+			return nil
+		}
+		tablewriter.PrintTableData(eventTableData(events))
+		fmt.Println()
+		summary.printTables()
+	default:
+		// the summary would break csv and json lines exports, so it goes to stderr
+		tablewriter.PrintTableData(eventTableData(events))
+		_, _ = fmt.Fprintln(os.Stderr, summary.String())
+	}
+	return nil
+}
+
+func eventTableData(events []api.EventEntry) []tablewriter.TableWriter {
+	outputData := make([]tablewriter.TableWriter, len(events))
+	for i, d := range events {
+		outputData[i] = tableEventEntry(d)
+	}
+	return outputData
+}
+
+// followHistory polls the event history and prints events that were not printed before, until interrupted
+func followHistory(ctx context.Context, filter *historyFilter, ssh, mToken string) error {
+	if tablewriter.Format() == tablewriter.FormatYAML {
+		return errors.New("--follow cannot be used with the yaml output format")
+	}
+	if historyOptions.Interval < time.Second {
+		return errors.New("--interval must be at least 1s")
+	}
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	printer := newEventStreamPrinter(os.Stdout)
+	seen := map[string]bool{}
+	ticker := time.NewTicker(historyOptions.Interval)
+	defer ticker.Stop()
+	for {
+		events, updatedToken, err := fetchHistory(ssh, mToken)
+		if err != nil {
+			_, _ = fmt.Fprintf(os.Stderr, "could not obtain event history: %s\n", err)
+		}
+		mToken = updatedToken
+		var fresh []api.EventEntry
+		for _, e := range filter.apply(events) {
+			key := eventKey(e)
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			fresh = append(fresh, e)
+		}
+		sort.SliceStable(fresh, func(i, j int) bool { return fresh[i].Time < fresh[j].Time })
+		for _, e := range fresh {
+			if err = printer.print(e); err != nil {
+				return err
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+func eventKey(e api.EventEntry) string {
+	return strings.Join(
+		[]string{
+			strconv.FormatInt(e.Time, 10),
+			string(e.Event),
+			e.MOMID,
+			e.IP,
+			e.UserAgent,
+			e.Comment,
+		}, "\x00",
+	)
+}
+
+// eventStreamPrinter prints single events as they arrive in the used output format
+type eventStreamPrinter struct {
+	out           io.Writer
+	csv           *csv.Writer
+	headerPrinted bool
+}
+
+func newEventStreamPrinter(out io.Writer) *eventStreamPrinter {
+	p := &eventStreamPrinter{out: out}
+	if tablewriter.Format() == tablewriter.FormatCSV {
+		p.csv = csv.NewWriter(out)
+	}
+	return p
+}
+
+func (p *eventStreamPrinter) print(e api.EventEntry) error {
+	switch tablewriter.Format() {
+	case tablewriter.FormatCSV:
+		if !p.headerPrinted {
+			if err := p.csv.Write(tableEventEntry(e).TableGetHeader()); err != nil {
+				return err
+			}
+			p.headerPrinted = true
+		}
+		if err := p.csv.Write(tableEventEntry(e).TableGetRow()); err != nil {
+			return err
+		}
+		p.csv.Flush()
+		return p.csv.Error()
+	case tablewriter.FormatJSON, tablewriter.FormatJSONLines:
+		data, err := json.Marshal(e)
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(p.out, string(data))
+		return err
+	default:
+		row := tableEventEntry(e).TableGetRow()
+		_, err := fmt.Fprintf(p.out, "%s  %-24s %-16s %s  %s\n", row[2], row[0], row[3], row[4], row[1])
+		return err
+	}
+}
+
+type historyCount struct {
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
+
+type historySummary struct {
+	Total  int            `json:"total"`
+	Events []historyCount `json:"events"`
+	IPs    []historyCount `json:"ips"`
+}
+
+func summarizeHistory(events []api.EventEntry) historySummary {
+	byEvent := map[string]int{}
+	byIP := map[string]int{}
+	for _, e := range events {
+		byEvent[string(e.Event)]++
+		ip := e.IP
+		if ip == "" {
+			ip = "unknown"
+		}
+		byIP[ip]++
+	}
+	return historySummary{
+		Total:  len(events),
+		Events: sortedHistoryCounts(byEvent),
+		IPs:    sortedHistoryCounts(byIP),
+	}
+}
+
+// sortedHistoryCounts sorts by descending count and then by name
+func sortedHistoryCounts(m map[string]int) []historyCount {
+	counts := make([]historyCount, 0, len(m))
+	for name, c := range m {
+		counts = append(
+			counts, historyCount{
+				Name:  name,
+				Count: c,
+			},
+		)
+	}
+	sort.Slice(
+		counts, func(i, j int) bool {
+			if counts[i].Count != counts[j].Count {
+				return counts[i].Count > counts[j].Count
+			}
+			return counts[i].Name < counts[j].Name
+		},
+	)
+	return counts
+}
+
+func (s historySummary) printTables() {
+	fmt.Println(s.total())
+	tablewriter.PrintTableData(historyCountTableData("Event", s.Events))
+	tablewriter.PrintTableData(historyCountTableData("IP", s.IPs))
+}
+
+func (s historySummary) String() string {
+	format := func(counts []historyCount) string {
+		parts := make([]string, len(counts))
+		for i, c := range counts {
+			parts[i] = fmt.Sprintf("%s: %d", c.Name, c.Count)
+		}
+		return strings.Join(parts, ", ")
+	}
+	return fmt.Sprintf("%s; by event: %s; by IP: %s", s.total(), format(s.Events), format(s.IPs))
+}
+
+func (s historySummary) total() string {
+	if s.Total == 1 {
+		return "1 event"
+	}
+	return fmt.Sprintf("%d events", s.Total)
+}
+
+type tableHistoryCount struct {
+	historyCount
+	kind string
+}
+
+func historyCountTableData(kind string, counts []historyCount) []tablewriter.TableWriter {
+	data := make([]tablewriter.TableWriter, len(counts))
+	for i, c := range counts {
+		data[i] = tableHistoryCount{
+			historyCount: c,
+			kind:         kind,
+		}
+	}
+	return data
+}
+
+func (c tableHistoryCount) TableGetHeader() []string {
+	return []string{
+		c.kind,
+		"Count",
+	}
+}
+
+func (c tableHistoryCount) TableGetRow() []string {
+	return []string{
+		c.Name,
+		strconv.Itoa(c.Count),
+	}
+}
@@ -0,0 +1,366 @@
+package commands
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/oidc-mytoken/api/v0"
+	mytokenlib "github.com/oidc-mytoken/lib"
+	"github.com/oidc-mytoken/utils/utils/issuerutils"
+	"github.com/pkg/errors"
+
+	"github.com/oidc-mytoken/client/internal/store"
+	"github.com/oidc-mytoken/client/internal/utils/color"
+	"github.com/oidc-mytoken/client/internal/utils/jwks"
+	"github.com/oidc-mytoken/client/internal/utils/timeexpr"
+)
+
+const infoTimeFmt = "2006-01-02 15:04:05 MST"
+
+// verifyMytokenSignature verifies the signature of a mytoken JWT against the key set of the issuing mytoken instance
+func verifyMytokenSignature(token, issuer string) (*jwks.Result, error) {
+	return jwks.Verify(
+		token, issuer, func() (string, error) {
+			server, err := mytokenlib.NewMytokenServer(issuer)
+			if err != nil {
+				return "", err
+			}
+			return server.ServerMetadata.JWKSURI, nil
+		},
+	)
+}
+
+// checkTrustedMytokenIssuer checks that the issuer of a mytoken is the configured mytoken instance or the issuer of a
+// mytoken in the mytoken store; the issuer is taken from the unverified token, so the key set of any other issuer
+// cannot be trusted to verify it
+func checkTrustedMytokenIssuer(issuer, configuredURL string) error {
+	if issuer == "" {
+		return errors.New("the mytoken does not name its issuer")
+	}
+	if configuredURL != "" && issuerutils.CompareIssuerURLs(configuredURL, issuer) {
+		return nil
+	}
+	if s, err := store.Load(); err == nil {
+		for _, e := range s.Entries {
+			if e.Issuer != "" && issuerutils.CompareIssuerURLs(e.Issuer, issuer) {
+				return nil
+			}
+		}
+	}
+	return errors.Errorf(
+		"the mytoken was issued by %s, which is neither the configured mytoken instance nor the issuer of a "+
+			"mytoken in the mytoken store", issuer,
+	)
+}
+
+// trustedIssuer returns the passed issuer if it is trusted as described for checkTrustedMytokenIssuer, otherwise an
+// empty string; only trusted mytoken instances are contacted for information about a token
+func trustedIssuer(issuer, configuredURL string) string {
+	if checkTrustedMytokenIssuer(issuer, configuredURL) != nil {
+		return ""
+	}
+	return issuer
+}
+
+// mytokenMOMIDFor returns the MOM-ID of a mytoken, which is not part of the token itself; it is taken from the local
+// mytoken store or, if the issuer is trusted, obtained by introspecting the token. An empty string is returned if the
+// MOM-ID cannot be derived.
+func mytokenMOMIDFor(token, trustedIss string) string {
+	if s, err := store.Load(); err == nil {
+		for _, e := range s.Entries {
+			if e.Mytoken == token && e.MOMID != "" {
+				return e.MOMID
+			}
+		}
+	}
+	if trustedIss == "" {
+		return ""
+	}
+	server, err := mytokenlib.NewMytokenServer(trustedIss)
+	if err != nil {
+		return ""
+	}
+	res, err := server.Tokeninfo.Introspect(token)
+	if err != nil {
+		return ""
+	}
+	return res.MOMID
+}
+
+// printMytokenDetails prints a human-readable description of a mytoken; trustedIss is the issuer of the mytoken if it
+// is trusted (see trustedIssuer), otherwise no request is sent to the issuer
+func printMytokenDetails(
+	out io.Writer, token string, mt api.Mytoken, trustedIss string, verification *jwks.Result,
+) {
+	now := time.Now()
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	name := mt.Name
+	if name == "" {
+		name = color.Italic("unnamed token")
+	}
+	_, _ = fmt.Fprintf(w, "Name:\t%s\n", name)
+	_, _ = fmt.Fprintf(w, "Token ID:\t%s\n", mt.ID)
+	if momID := mytokenMOMIDFor(token, trustedIss); momID != "" {
+		_, _ = fmt.Fprintf(w, "MOM-ID:\t%s\n", momID)
+	}
+	_, _ = fmt.Fprintf(w, "Issuer:\t%s\n", mt.Issuer)
+	_, _ = fmt.Fprintf(w, "Subject:\t%s\n", mt.Subject)
+	_, _ = fmt.Fprintf(w, "OIDC Issuer:\t%s\n", mt.OIDCIssuer)
+	_, _ = fmt.Fprintf(w, "OIDC Subject:\t%s\n", mt.OIDCSubject)
+	if mt.IssuedAt != 0 {
+		_, _ = fmt.Fprintf(
+			w, "Issued:\t%s (%s)\n", time.Unix(mt.IssuedAt, 0).Format(infoTimeFmt), timeexpr.Relative(mt.IssuedAt, now),
+		)
+	}
+	_, _ = fmt.Fprintf(w, "Expires:\t%s\n", describeExpiry(mt.ExpiresAt, now))
+	if verification != nil {
+		src := "downloaded key"
+		if verification.FromCache {
+			src = "cached key"
+		}
+		_, _ = fmt.Fprintf(
+			w, "Signature:\tvalid (%s, key '%s', %s)\n", verification.Algorithm, verification.KeyID, src,
+		)
+	}
+	_ = w.Flush()
+
+	_, _ = fmt.Fprintln(out)
+	_, _ = fmt.Fprintln(out, "Capabilities:")
+	printTokenCapabilities(out, trustedIss, mt.Capabilities)
+
+	_, _ = fmt.Fprintln(out)
+	_, _ = fmt.Fprintln(out, "Restrictions:")
+	printTokenRestrictions(out, mt.Restrictions, now)
+
+	_, _ = fmt.Fprintln(out)
+	_, _ = fmt.Fprintf(out, "Rotation: %s\n", describeRotation(mt.Rotation))
+}
+
+func describeExpiry(exp int64, now time.Time) string {
+	if exp == 0 {
+		return color.Italic("does not expire")
+	}
+	s := time.Unix(exp, 0).Format(infoTimeFmt)
+	if exp < now.Unix() {
+		return color.Gray(fmt.Sprintf("%s (expired %s)", s, timeexpr.Relative(exp, now)))
+	}
+	return fmt.Sprintf("%s (%s)", s, timeexpr.Relative(exp, now))
+}
+
+// printTokenCapabilities prints the capabilities of a token grouped by the capability tree of the mytoken server with
+// the passed trusted issuer url; if the issuer is empty or the tree cannot be obtained, the tree is derived from the
+// known capabilities
+func printTokenCapabilities(out io.Writer, trustedIss string, capabilities api.Capabilities) {
+	if len(capabilities) == 0 {
+		_, _ = fmt.Fprintln(out, "  none")
+		return
+	}
+	var tree []CapabilityEntry
+	if trustedIss != "" {
+		tree, _ = fetchCapabilityTree(trustedIss)
+	}
+	if len(tree) == 0 {
+		tree = localCapabilityTree()
+	}
+	held := make(map[string]bool, len(capabilities))
+	for _, c := range capabilities {
+		held[c.Name] = true
+	}
+	covered := map[string]bool{}
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	printHeldCapabilities(w, tree, held, covered, "  ")
+	for _, c := range capabilities {
+		if !covered[c.Name] {
+			_, _ = fmt.Fprintf(w, "  %s\t%s\n", c.Name, c.Description)
+		}
+	}
+	_ = w.Flush()
+}
+
+// printHeldCapabilities prints the part of the capability tree that is held; a held capability includes all its
+// sub-capabilities, so these are not listed separately
+func printHeldCapabilities(w io.Writer, tree []CapabilityEntry, held, covered map[string]bool, indent string) {
+	for _, e := range tree {
+		name := e.ReadWriteCapability.Name
+		if held[name] {
+			desc := e.ReadWriteCapability.Description
+			if len(e.Children) > 0 {
+				desc += " (including all sub-capabilities)"
+			}
+			_, _ = fmt.Fprintf(w, "%s%s\t%s\n", indent, name, desc)
+			markCapabilitiesCovered(e, covered)
+			continue
+		}
+		readName := "read@" + name
+		if held[readName] {
+			desc := ""
+			if e.ReadOnlyCapability != nil {
+				desc = e.ReadOnlyCapability.Description
+			}
+			_, _ = fmt.Fprintf(w, "%s%s\t%s\n", indent, readName, desc)
+			covered[readName] = true
+			printHeldCapabilities(w, e.Children, held, covered, indent+"  ")
+			continue
+		}
+		if !treeHoldsAny(e.Children, held) {
+			continue
+		}
+		_, _ = fmt.Fprintf(w, "%s%s\t%s\n", indent, name, "(partially)")
+		printHeldCapabilities(w, e.Children, held, covered, indent+"  ")
+	}
+}
+
+func treeHoldsAny(tree []CapabilityEntry, held map[string]bool) bool {
+	for _, e := range tree {
+		if held[e.ReadWriteCapability.Name] || held["read@"+e.ReadWriteCapability.Name] ||
+			treeHoldsAny(e.Children, held) {
+			return true
+		}
+	}
+	return false
+}
+
+func markCapabilitiesCovered(e CapabilityEntry, covered map[string]bool) {
+	covered[e.ReadWriteCapability.Name] = true
+	covered["read@"+e.ReadWriteCapability.Name] = true
+	for _, c := range e.Children {
+		markCapabilitiesCovered(c, covered)
+	}
+}
+
+// localCapabilityTree builds a capability tree from the capabilities known to the client; sub-capabilities are
+// named 'parent:child' and read-only capabilities 'read@name'
+func localCapabilityTree() []CapabilityEntry {
+	readOnly := map[string]api.Capability{}
+	readWrite := map[string]bool{}
+	for _, c := range api.AllCapabilities {
+		if strings.HasPrefix(c.Name, "read@") {
+			readOnly[strings.TrimPrefix(c.Name, "read@")] = c
+		} else {
+			readWrite[c.Name] = true
+		}
+	}
+	parentOf := func(name string) string {
+		if i := strings.LastIndex(name, ":"); i > 0 && readWrite[name[:i]] {
+			return name[:i]
+		}
+		return ""
+	}
+	var build func(parent string) []CapabilityEntry
+	build = func(parent string) []CapabilityEntry {
+		var entries []CapabilityEntry
+		for _, c := range api.AllCapabilities {
+			if !readWrite[c.Name] || parentOf(c.Name) != parent {
+				continue
+			}
+			e := CapabilityEntry{
+				ReadWriteCapability: CapabilityInfo{
+					Name:        c.Name,
+					Description: c.Description,
+				},
+				Children: build(c.Name),
+			}
+			if ro, ok := readOnly[c.Name]; ok {
+				e.ReadOnlyCapability = &CapabilityInfo{
+					Name:        ro.Name,
+					Description: ro.Description,
+				}
+			}
+			entries = append(entries, e)
+		}
+		return entries
+	}
+	return build("")
+}
+
+// printTokenRestrictions prints each restriction clause with the remaining time and usage limits
+func printTokenRestrictions(out io.Writer, restrictions api.Restrictions, now time.Time) {
+	if len(restrictions) == 0 {
+		_, _ = fmt.Fprintln(out, "  none, the mytoken can be used without restrictions")
+		return
+	}
+	if len(restrictions) > 1 {
+		_, _ = fmt.Fprintln(out, "  The mytoken can be used if any of these clauses is fulfilled.")
+	}
+	for i, r := range restrictions {
+		if r == nil {
+			continue
+		}
+		expired := r.ExpiresAt != 0 && r.ExpiresAt < now.Unix()
+		header := fmt.Sprintf("  Clause %d", i+1)
+		if expired {
+			header += " (expired, cannot be used anymore)"
+		}
+		var lines []string
+		if r.NotBefore != 0 {
+			s := time.Unix(r.NotBefore, 0).Format(infoTimeFmt)
+			if r.NotBefore > now.Unix() {
+				s += fmt.Sprintf(" (starts %s)", timeexpr.Relative(r.NotBefore, now))
+			}
+			lines = append(lines, "Valid from:\t"+s)
+		}
+		if r.ExpiresAt != 0 {
+			lines = append(lines, "Valid until:\t"+describeExpiry(r.ExpiresAt, now))
+		}
+		if r.Scope != "" {
+			lines = append(lines, "Scopes:\t"+r.Scope)
+		}
+		if len(r.Audiences) > 0 {
+			lines = append(lines, "Audiences:\t"+strings.Join(r.Audiences, ", "))
+		}
+		if len(r.Hosts) > 0 {
+			lines = append(lines, "Hosts:\t"+strings.Join(r.Hosts, ", "))
+		}
+		if len(r.GeoIPAllow) > 0 {
+			lines = append(lines, "Countries allowed:\t"+strings.Join(r.GeoIPAllow, ", "))
+		}
+		if len(r.GeoIPDisallow) > 0 {
+			lines = append(lines, "Countries disallowed:\t"+strings.Join(r.GeoIPDisallow, ", "))
+		}
+		if r.UsagesAT != nil {
+			lines = append(lines, fmt.Sprintf("Access token usages:\tat most %d", *r.UsagesAT))
+		}
+		if r.UsagesOther != nil {
+			lines = append(lines, fmt.Sprintf("Other usages:\tat most %d", *r.UsagesOther))
+		}
+		if len(r.IncludedProfiles) > 0 {
+			lines = append(lines, "Included profiles:\t"+strings.Join(r.IncludedProfiles, ", "))
+		}
+		if len(lines) == 0 {
+			lines = append(lines, "No limits:\tthis clause does not restrict the mytoken")
+		}
+		if expired {
+			header = color.Gray(header)
+		}
+		_, _ = fmt.Fprintln(out, header)
+		w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+		for _, l := range lines {
+			_, _ = fmt.Fprintf(w, "    %s\n", l)
+		}
+		_ = w.Flush()
+	}
+}
+
+func describeRotation(r *api.Rotation) string {
+	if r == nil || (!r.OnAT && !r.OnOther) {
+		return "disabled"
+	}
+	var on []string
+	if r.OnAT {
+		on = append(on, "when obtaining access tokens")
+	}
+	if r.OnOther {
+		on = append(on, "on other usages")
+	}
+	s := "rotated " + strings.Join(on, " and ")
+	if r.Lifetime != 0 {
+		s += fmt.Sprintf("; a rotated mytoken is valid for %s", timeexpr.FormatDuration(time.Duration(r.Lifetime)*time.Second))
+	}
+	if r.AutoRevoke {
+		s += "; automatically revoked on suspected abuse"
+	}
+	return s
+}
@@ -0,0 +1,309 @@
+package commands
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/signal"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/oidc-mytoken/api/v0"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"github.com/urfave/cli/v3"
+
+	"github.com/oidc-mytoken/client/internal/config"
+	cutils "github.com/oidc-mytoken/client/internal/utils"
+)
+
+var metricsOptions = struct {
+	MTOptions
+	Textfile string
+	Listen   string
+	CacheFor time.Duration
+}{}
+
+func init() {
+	app.Commands = append(
+		app.Commands, &cli.Command{
+			Name: "metrics",
+			Usage: "Exports metrics about the expiration and usages of your mytokens in the Prometheus text format; " +
+				"by default the metrics are printed",
+			Action: metrics,
+			Flags: appendMTFlags(
+				&cli.StringFlag{
+					Name: "textfile",
+					Usage: "Write the metrics to `FILE`, e.g. for the textfile collector of the node exporter; " +
+						"the file is replaced atomically",
+					Destination: &metricsOptions.Textfile,
+				},
+				&cli.StringFlag{
+					Name: "listen",
+					Usage: "Serve the metrics via http on `ADDRESS` (e.g. ':9877') under /metrics; " +
+						"collecting the metrics lists your mytokens and introspects the used mytoken, " +
+						"which counts as a usage of it and might rotate it",
+					Destination: &metricsOptions.Listen,
+				},
+				&cli.DurationFlag{
+					Name: "cache-for",
+					Usage: "With --listen, answer scrapes within `DURATION` after a collection with the same " +
+						"metrics instead of contacting the mytoken server again",
+					Value:       time.Minute,
+					Destination: &metricsOptions.CacheFor,
+				},
+			),
+		},
+	)
+}
+
+// metricsCollector collects the metrics; it keeps the mytoken, since it might be rotated between collections,
+// and the last collected metrics, so that they can be reused for scrapes within cacheFor
+type metricsCollector struct {
+	mutex       sync.Mutex
+	ssh         string
+	mytoken     string
+	cacheFor    time.Duration
+	cached      []byte
+	collectedAt time.Time
+}
+
+func newMetricsCollector() *metricsCollector {
+	c := &metricsCollector{
+		ssh:      metricsOptions.SSH(),
+		cacheFor: metricsOptions.CacheFor,
+	}
+	if c.ssh == "" {
+		c.mytoken = metricsOptions.MustGetToken()
+	}
+	return c
+}
+
+func metrics(ctx context.Context, _ *cli.Command) error {
+	if metricsOptions.Textfile != "" && metricsOptions.Listen != "" {
+		return errors.New("--textfile and --listen cannot be used together")
+	}
+	collector := newMetricsCollector()
+	if metricsOptions.Listen != "" {
+		return collector.serve(ctx, metricsOptions.Listen)
+	}
+	var buf bytes.Buffer
+	if err := collector.collect(&buf); err != nil {
+		return err
+	}
+	if metricsOptions.Textfile != "" {
+		return cutils.WriteFileAtomic(metricsOptions.Textfile, buf.Bytes(), 0644)
+	}
+	_, err := os.Stdout.Write(buf.Bytes())
+	return err
+}
+
+func (c *metricsCollector) serve(ctx context.Context, addr string) error {
+	mux := http.NewServeMux()
+	mux.HandleFunc(
+		"/metrics", func(w http.ResponseWriter, _ *http.Request) {
+			data, err := c.collectCached()
+			if err != nil {
+				log.WithError(err).Error("could not collect metrics")
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+			_, _ = w.Write(data)
+		},
+	)
+	server := &http.Server{
+		Addr:              addr,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = server.Shutdown(shutdownCtx)
+	}()
+	_, _ = fmt.Fprintf(os.Stderr, "Serving metrics on http://%s/metrics\n", addr)
+	if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// collectCached returns the last collected metrics if they are not older than cacheFor and collects them otherwise
+func (c *metricsCollector) collectCached() ([]byte, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.cached != nil && time.Since(c.collectedAt) < c.cacheFor {
+		return c.cached, nil
+	}
+	var buf bytes.Buffer
+	if err := c.collectLocked(&buf); err != nil {
+		return nil, err
+	}
+	c.cached = buf.Bytes()
+	c.collectedAt = time.Now()
+	return c.cached, nil
+}
+
+// collect obtains the list of mytokens and the introspection of the used mytoken and writes the metrics
+func (c *metricsCollector) collect(w io.Writer) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.collectLocked(w)
+}
+
+// collectLocked is collect for callers that already hold the mutex
+func (c *metricsCollector) collectLocked(w io.Writer) error {
+	var tree []api.MytokenEntryTree
+	var introspection *api.TokeninfoIntrospectResponse
+	var err error
+	if c.ssh != "" {
+		var res *api.TokeninfoListResponse
+		res, err = doSSHParseJSON[api.TokeninfoListResponse](c.ssh, api.SSHRequestTokenInfoListMytokens, nil)
+		if err != nil {
+			return err
+		}
+		tree = res.Tokens
+		introspection, err = doSSHParseJSON[api.TokeninfoIntrospectResponse](
+			c.ssh, api.SSHRequestTokenInfoIntrospect, nil,
+		)
+	} else {
+		mytoken := config.Get().Mytoken()
+		var res api.TokeninfoListResponse
+		res, err = mytoken.Tokeninfo.APIListMytokens(c.mytoken)
+		if err != nil {
+			return err
+		}
+		if res.TokenUpdate != nil {
+			updateMytoken(res.TokenUpdate.Mytoken)
+			c.mytoken = res.TokenUpdate.Mytoken
+		}
+		tree = res.Tokens
+		introspection, err = mytoken.Tokeninfo.Introspect(c.mytoken)
+	}
+	if err != nil {
+		return err
+	}
+
+	expiresAt := newMetricFamily(
+		"mytoken_expires_at_seconds", "Expiration time of the mytoken as unix timestamp; "+
+			"mytokens that do not expire are omitted.",
+	)
+	created := newMetricFamily("mytoken_created_seconds", "Creation time of the mytoken as unix timestamp.")
+	subtokens := newMetricFamily("mytoken_subtokens_total", "Number of subtokens of the mytoken.")
+	remaining := newMetricFamily(
+		"mytoken_remaining_usages", "Remaining usages of the used mytoken; kind is AT for obtaining access tokens "+
+			"and other for all other usages. Unlimited usages are omitted.",
+	)
+	count := newMetricFamily("mytoken_mytokens", "Number of mytokens in the list of mytokens.")
+
+	var walk func(entries []api.MytokenEntryTree)
+	walk = func(entries []api.MytokenEntryTree) {
+		for _, e := range entries {
+			t := e.Token
+			tags := make([]string, len(t.Tags))
+			for i, tag := range t.Tags {
+				tags[i] = string(tag.Tag)
+			}
+			sort.Strings(tags)
+			labels := metricLabels{
+				{"name", t.Name},
+				{"mom_id", t.MOMID},
+				{"tags", strings.Join(tags, ",")},
+			}
+			if t.ExpiresAt != 0 {
+				expiresAt.add(labels, float64(t.ExpiresAt))
+			}
+			created.add(labels, float64(t.CreatedAt))
+			subtokens.add(labels, float64(countSubtokens(e.Children)))
+			walk(e.Children)
+		}
+	}
+	walk(tree)
+	count.add(nil, float64(countSubtokens(tree)))
+
+	if introspection.Valid {
+		now := time.Now().Unix()
+		for _, kind := range []string{"AT", "other"} {
+			if n, limited := remainingUsages(introspection.Token, now, kind == "other"); limited {
+				remaining.add(
+					metricLabels{
+						{"name", introspection.Token.Name},
+						{"mom_id", introspection.MOMID},
+						{"kind", kind},
+					}, float64(n),
+				)
+			}
+		}
+	}
+
+	for _, f := range []*metricFamily{expiresAt, created, subtokens, remaining, count} {
+		if err = f.write(w); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type metricLabels [][2]string
+
+type metricSample struct {
+	labels metricLabels
+	value  float64
+}
+
+// metricFamily is a gauge in the Prometheus text exposition format
+type metricFamily struct {
+	name    string
+	help    string
+	samples []metricSample
+}
+
+func newMetricFamily(name, help string) *metricFamily {
+	return &metricFamily{
+		name: name,
+		help: help,
+	}
+}
+
+func (f *metricFamily) add(labels metricLabels, value float64) {
+	f.samples = append(
+		f.samples, metricSample{
+			labels: labels,
+			value:  value,
+		},
+	)
+}
+
+func (f *metricFamily) write(w io.Writer) error {
+	if len(f.samples) == 0 {
+		return nil
+	}
+	var b strings.Builder
+	_, _ = fmt.Fprintf(&b, "# HELP %s %s\n", f.name, strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(f.help))
+	_, _ = fmt.Fprintf(&b, "# TYPE %s gauge\n", f.name)
+	escape := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+	for _, s := range f.samples {
+		b.WriteString(f.name)
+		if len(s.labels) > 0 {
+			pairs := make([]string, len(s.labels))
+			for i, l := range s.labels {
+				pairs[i] = fmt.Sprintf(`%s="%s"`, l[0], escape.Replace(l[1]))
+			}
+			b.WriteString("{" + strings.Join(pairs, ",") + "}")
+		}
+		b.WriteString(" " + strconv.FormatFloat(s.value, 'f', -1, 64) + "\n")
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
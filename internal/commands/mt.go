@@ -7,6 +7,7 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/pkg/errors"
 
@@ -15,12 +16,13 @@ import (
 	"github.com/oidc-mytoken/utils/utils"
 	"github.com/oidc-mytoken/utils/utils/jsonutils"
 	"github.com/oidc-mytoken/utils/utils/profile"
-	"github.com/oidc-mytoken/utils/utils/timerestriction"
 	"github.com/urfave/cli/v3"
 
 	"github.com/oidc-mytoken/client/internal/config"
 	cutils "github.com/oidc-mytoken/client/internal/utils"
+	"github.com/oidc-mytoken/client/internal/utils/policyfile"
 	"github.com/oidc-mytoken/client/internal/utils/qr"
+	"github.com/oidc-mytoken/client/internal/utils/timeexpr"
 )
 
 type restrictionOpts struct {
@@ -34,6 +36,7 @@ type restrictionOpts struct {
 	RestrictGeoIPDisallow []string
 	RestrictUsagesOther   int64
 	RestrictUsagesAT      int64
+	RestrictionClauses    []string
 }
 
 type rotationOPts struct {
@@ -54,13 +57,16 @@ type mtOpts struct {
 	MTOptions
 	TransferCode string
 	UseOIDCFlow  bool
+	OIDCFlow     string
+	OIDCTimeout  time.Duration
 
 	profile string
 	profileOpts
 	Tags    []string
 	request *api.GeneralMytokenRequest
 
-	Out string
+	Out    string
+	DryRun bool
 }
 
 var mtCommand mtOpts
@@ -80,6 +86,15 @@ func (opts *mtOpts) parseProviderOpt() error {
 		return nil
 	}
 	pp, ok := config.Get().Providers[opts.provider]
+	if !ok {
+		if opts.DryRun {
+			// the providers supported by the server cannot be looked up without contacting it, so an issuer url
+			// without its scheme is accepted without checking that the server supports it
+			pp, ok = issuerFromHostname(opts.provider)
+		} else {
+			pp, ok = supportedProviderWithoutScheme(opts.provider)
+		}
+	}
 	if !ok {
 		return fmt.Errorf(
 			"Provider name '%s' not found in config file. Please provide a valid provider name or the provider url.",
@@ -90,6 +105,33 @@ func (opts *mtOpts) parseProviderOpt() error {
 	return nil
 }
 
+// supportedProviderWithoutScheme returns the issuer url of the provider supported by the mytoken server that matches
+// the passed issuer url without its scheme; this form is offered by the shell completion, because colons cannot be
+// completed
+func supportedProviderWithoutScheme(provider string) (string, bool) {
+	provider = strings.TrimSuffix(provider, "/")
+	for _, p := range config.Get().Mytoken().ServerMetadata.ProvidersSupported {
+		if strings.TrimSuffix(issuerWithoutScheme(p.Issuer), "/") == provider {
+			return p.Issuer, true
+		}
+	}
+	return "", false
+}
+
+// issuerFromHostname returns the issuer url for a provider given as an issuer url without its scheme; a provider name
+// without a dot is not a hostname
+func issuerFromHostname(provider string) (string, bool) {
+	host, _, _ := strings.Cut(provider, "/")
+	if !strings.Contains(host, ".") {
+		return "", false
+	}
+	return "https://" + provider, true
+}
+
+func issuerWithoutScheme(issuer string) string {
+	return strings.TrimPrefix(issuer, "https://")
+}
+
 func (opts *mtOpts) parseCapabilitiesOption() error {
 	if opts.CapabilitiesStr == "" {
 		return nil
@@ -106,15 +148,25 @@ func (opts *mtOpts) parseCapabilitiesOption() error {
 
 func (opts *mtOpts) parseRotationOption() error {
 	rotStr := opts.RotationStr
-	if rotStr == "" {
-		return nil
-	}
 	rotBytes := []byte(rotStr)
-	if jsonutils.IsJSONObject(rotBytes) {
+	switch {
+	case rotStr == "":
+		if !opts.OnAT && !opts.OnOther && !opts.AutoRevoke && opts.Lifetime == 0 {
+			return nil
+		}
+		opts.request.Rotation = &api.Rotation{}
+	case jsonutils.IsJSONObject(rotBytes):
+		opts.request.Rotation = &api.Rotation{}
 		if err := json.Unmarshal(rotBytes, opts.request.Rotation); err != nil {
 			return err
 		}
-	} else {
+	case looksLikeFilePath(rotStr):
+		rot, err := parseRotationFromFile(rotStr)
+		if err != nil {
+			return err
+		}
+		opts.request.Rotation = rot
+	default:
 		opts.request.Rotation = &api.Rotation{IncludedProfiles: strings.Split(rotStr, " ")}
 	}
 	opts.request.Rotation.OnAT = opts.request.Rotation.OnAT || opts.OnAT
@@ -126,6 +178,18 @@ func (opts *mtOpts) parseRotationOption() error {
 	return nil
 }
 
+func parseRotationFromFile(path string) (*api.Rotation, error) {
+	data, err := readPolicyFile(path, "rotation")
+	if err != nil {
+		return nil, err
+	}
+	rot := &api.Rotation{}
+	if err = decodePolicyFile(data, "rotation", rot); err != nil {
+		return nil, errors.Wrapf(err, "invalid rotation file %s", path)
+	}
+	return rot, nil
+}
+
 func looksLikeFilePath(arg string) bool {
 	if strings.HasPrefix(arg, "./") || strings.HasPrefix(arg, "../") {
 		return true
@@ -152,38 +216,68 @@ func expandTilde(path string) (string, error) {
 	return filepath.Join(homeDir, path[2:]), nil
 }
 
-func parseRestrictionsFromFile(path string) (api.Restrictions, error) {
+// readPolicyFile reads a json or yaml file given on the command line; a leading ~/ is expanded. kind describes the
+// content of the file for error messages.
+func readPolicyFile(path, kind string) (any, error) {
 	expandedPath, err := expandTilde(path)
 	if err != nil {
 		return nil, err
 	}
-
-	content, err := os.ReadFile(expandedPath)
+	data, err := policyfile.Read(expandedPath)
 	if err != nil {
-		if os.IsNotExist(err) {
-			return nil, errors.Errorf("restrictions file not found: %s", expandedPath)
+		if os.IsNotExist(errors.Cause(err)) {
+			return nil, errors.Errorf("%s file not found: %s", kind, expandedPath)
 		}
-		return nil, errors.Wrapf(err, "could not read restrictions file %s", expandedPath)
+		return nil, errors.Wrapf(err, "could not read %s file %s", kind, expandedPath)
 	}
+	return data, nil
+}
 
-	rBytes := content
-	if jsonutils.IsJSONObject(rBytes) {
-		rBytes = append([]byte{'['}, append(rBytes, ']')...)
+func parseRestrictionsFromFile(path string) (api.Restrictions, error) {
+	data, err := readPolicyFile(path, "restrictions")
+	if err != nil {
+		return nil, err
+	}
+	switch data.(type) {
+	case map[string]any:
+		data = []any{data}
+	case []any:
+	default:
+		return nil, errors.Errorf("restrictions file must contain an object or a list: %s", path)
 	}
-
 	var restrictions api.Restrictions
-	if !jsonutils.IsJSONArray(rBytes) {
-		return nil, errors.Errorf("restrictions file must contain a JSON object or array: %s", expandedPath)
+	if err = decodePolicyFile(data, "restrictions", &restrictions); err != nil {
+		return nil, errors.Wrapf(err, "invalid restrictions file %s", path)
 	}
+	return restrictions, nil
+}
 
-	if err = json.Unmarshal(rBytes, &restrictions); err != nil {
-		return nil, errors.Wrapf(err, "could not parse restrictions file %s", expandedPath)
+// parseTimeOption parses a time expression for a restriction and prints the resolved time to stderr, so it can be
+// checked before the request is sent
+func parseTimeOption(name, value string) (int64, error) {
+	t, err := timeexpr.Parse(value, time.Now())
+	if err != nil || t == 0 {
+		return t, err
 	}
+	_, _ = fmt.Fprintf(os.Stderr, "%s '%s' resolves to %s\n", name, value, timeexpr.Describe(t))
+	return t, nil
+}
 
-	return restrictions, nil
+func (opts *mtOpts) parseRestrictionOpts(cmd *cli.Command) error {
+	if err := opts.parseRestrictionBaseOpts(cmd); err != nil {
+		return err
+	}
+	clauses, err := parseRestrictionClauses(opts.RestrictionClauses)
+	if err != nil {
+		return err
+	}
+	opts.request.Restrictions = append(opts.request.Restrictions, clauses...)
+	return nil
 }
 
-func (opts *mtOpts) parseRestrictionOpts(cmd *cli.Command) (err error) {
+// parseRestrictionBaseOpts parses the restrictions given by --restrictions or, if not set, the single restriction
+// clause built from the other restriction options
+func (opts *mtOpts) parseRestrictionBaseOpts(cmd *cli.Command) (err error) {
 	if opts.Restrictions != "" {
 		rBytes := []byte(opts.Restrictions)
 
@@ -210,11 +304,11 @@ func (opts *mtOpts) parseRestrictionOpts(cmd *cli.Command) (err error) {
 		}
 		return nil
 	}
-	nbf, err := timerestriction.ParseTime(opts.RestrictNbf)
+	nbf, err := parseTimeOption("nbf", opts.RestrictNbf)
 	if err != nil {
 		return
 	}
-	exp, err := timerestriction.ParseTime(opts.RestrictExp)
+	exp, err := parseTimeOption("exp", opts.RestrictExp)
 	if err != nil {
 		return
 	}
@@ -234,7 +328,7 @@ func (opts *mtOpts) parseRestrictionOpts(cmd *cli.Command) (err error) {
 		rr.UsagesOther = utils.NewInt64(opts.RestrictUsagesOther)
 	}
 	if rr.UsagesAT != nil || rr.UsagesOther != nil || rr.NotBefore != 0 || rr.ExpiresAt != 0 || rr.Scope != "" ||
-		len(rr.Audiences) != 0 || len(rr.Hosts) != 0 || len(rr.GeoIPAllow) != 0 || len(rr.GeoIPAllow) != 0 {
+		len(rr.Audiences) != 0 || len(rr.Hosts) != 0 || len(rr.GeoIPAllow) != 0 || len(rr.GeoIPDisallow) != 0 {
 		opts.request.Restrictions = api.Restrictions{rr}
 	}
 	return
@@ -254,6 +348,42 @@ func parseRestrictionOpts(rOpts restrictionOpts, cmd *cli.Command) (api.Restrict
 	return mto.request.Restrictions, nil
 }
 
+// decodePolicyFile decodes the data of a policy file into v and prints a warning for each unknown field, since these
+// are ignored
+func decodePolicyFile(data any, name string, v any) error {
+	unknown, err := policyfile.Decode(data, name, v)
+	for _, u := range unknown {
+		_, _ = fmt.Fprintf(os.Stderr, "Warning: %s, it is ignored\n", u)
+	}
+	return err
+}
+
+func parseProfileFromFile(path string, req *api.GeneralMytokenRequest) error {
+	data, err := readPolicyFile(path, "profile")
+	if err != nil {
+		return err
+	}
+	if err = decodePolicyFile(data, "profile", req); err != nil {
+		return errors.Wrapf(err, "invalid profile file %s", path)
+	}
+	return nil
+}
+
+// finalRequest returns the request as it is sent to the server, i.e. with the token name prefix and the application
+// name applied
+func (opts *mtOpts) finalRequest(ctx context.Context, cmd *cli.Command) (*api.GeneralMytokenRequest, error) {
+	req, err := opts.Request(ctx, cmd)
+	if err != nil {
+		return nil, err
+	}
+	prefix := config.Get().TokenNamePrefix
+	if req.Name != "" && prefix != "" {
+		req.Name = fmt.Sprintf("%s:%s", prefix, req.Name)
+	}
+	req.ApplicationName = fmt.Sprintf("mytoken client on %s", config.Get().Hostname)
+	return req, nil
+}
+
 func (opts *mtOpts) Request(_ context.Context, cmd *cli.Command) (*api.GeneralMytokenRequest, error) {
 	if opts.request != nil {
 		return opts.request, nil
@@ -265,6 +395,10 @@ func (opts *mtOpts) Request(_ context.Context, cmd *cli.Command) (*api.GeneralMy
 			if err := json.Unmarshal(bProf, &opts.request); err != nil {
 				return nil, err
 			}
+		} else if looksLikeFilePath(opts.profile) {
+			if err := parseProfileFromFile(opts.profile, opts.request); err != nil {
+				return nil, err
+			}
 		} else {
 			opts.request.IncludedProfiles = strings.Split(opts.profile, " ")
 		}
@@ -325,7 +459,7 @@ func getRestrFlags(opts *restrictionOpts) []cli.Flag {
 			Name:    "restrictions",
 			Aliases: []string{"restriction"},
 			Usage: "The restrictions that restrict the requested mytoken. " +
-				"Can be a JSON object/array, a path to a JSON file (supports ~/... paths), " +
+				"Can be a JSON object/array, a path to a JSON or YAML file (supports ~/... paths), " +
 				"or space-separated profile/template names.",
 			Sources: cli.EnvVars(
 				"MYTOKEN_RESTRICTIONS",
@@ -357,15 +491,13 @@ func getRestrFlags(opts *restrictionOpts) []cli.Flag {
 			Name:    "exp",
 			Aliases: []string{"naf"},
 			Usage: "Restrict the mytoken so that it cannot be used after `EXP`. " +
-				"The time can be given as an absolute time given as a unix timestamp, " +
-				"a relative time string starting with '+' or an absolute time string '2006-01-02 15:04'.",
+				timeexpr.Help,
 			Destination: &opts.RestrictExp,
 		},
 		&cli.StringFlag{
 			Name: "nbf",
 			Usage: "Restrict the mytoken so that it cannot be used before `NBF`. " +
-				"The time can be given as an absolute time given as a unix timestamp, " +
-				"a relative time string starting with '+' or an absolute time string '2006-01-02 15:04'.",
+				timeexpr.Help,
 			Destination: &opts.RestrictNbf,
 		},
 		&cli.StringSliceFlag{
@@ -405,14 +537,21 @@ func getRestrFlags(opts *restrictionOpts) []cli.Flag {
 			DefaultText: "infinite",
 			Destination: &opts.RestrictUsagesOther,
 		},
+		&cli.StringSliceFlag{
+			Name:        "restriction-clause",
+			Usage:       restrictionClauseUsage,
+			Destination: &opts.RestrictionClauses,
+		},
 	}
 }
 func getRotationFlags(rotStr *string, rot *api.Rotation) []cli.Flag {
 	return []cli.Flag{
 		&cli.StringFlag{
-			Name:        "rotation",
-			Aliases:     []string{"rotate"},
-			Usage:       "The rotation policy for the requested mytoken. Can be a json object or a path to a json file.'",
+			Name:    "rotation",
+			Aliases: []string{"rotate"},
+			Usage: "The rotation policy for the requested mytoken. " +
+				"Can be a JSON object, a path to a JSON or YAML file (supports ~/... paths), " +
+				"or space-separated profile names; the other rotation options are merged into it.",
 			Sources:     cli.EnvVars("MYTOKEN_ROTATION"),
 			Destination: rotStr,
 		},
@@ -457,8 +596,10 @@ func init() {
 		append(
 			[]cli.Flag{
 				&cli.StringFlag{
-					Name:        "profile",
-					Usage:       "A mytoken profile describing the properties of the mytoken to be requested",
+					Name: "profile",
+					Usage: "A mytoken profile describing the properties of the mytoken to be requested. " +
+						"Can be a JSON object, a path to a JSON or YAML file (supports ~/... paths), " +
+						"or space-separated profile names.",
 					Sources:     cli.EnvVars("MYTOKEN_PROFILE"),
 					Destination: &mtCommand.profile,
 				},
@@ -476,14 +617,32 @@ func init() {
 			Usage:       "Use an OpenID Connect flow to create a mytoken",
 			Destination: &mtCommand.UseOIDCFlow,
 		},
+		&cli.StringFlag{
+			Name: "oidc-flow",
+			Usage: fmt.Sprintf(
+				"The `FLOW` used to obtain the mytoken through OIDC; one of %s. "+
+					"'polling' shows a url and qr code that can be used on any device; "+
+					"'loopback' opens the browser and polls as soon as the redirect arrives on 127.0.0.1",
+				strings.Join(oidcFlows, ", "),
+			),
+			Sources:     cli.EnvVars("MYTOKEN_OIDC_FLOW"),
+			Value:       oidcFlowPolling,
+			Destination: &mtCommand.OIDCFlow,
+		},
+		&cli.DurationFlag{
+			Name:        "oidc-timeout",
+			Usage:       "The maximum `DURATION` to wait for the authorization in the loopback OIDC flow",
+			Value:       5 * time.Minute,
+			Destination: &mtCommand.OIDCTimeout,
+		},
 		&cli.StringFlag{
 			Name: "provider",
 			Aliases: []string{
 				"i",
 				"issuer",
 			},
-			Usage: "The name or issuer url of the OpenID provider that should be used; only needed if mytoken is" +
-				" obtained through OIDC",
+			Usage: "The name or issuer url (the scheme can be omitted) of the OpenID provider that should be used; " +
+				"only needed if mytoken is obtained through OIDC",
 			Sources:     cli.EnvVars("MYTOKEN_PROVIDER"),
 			Destination: &mtCommand.provider,
 		},
@@ -529,6 +688,12 @@ func init() {
 			Value:       os.Stdout.Name(),
 			Destination: &mtCommand.Out,
 		},
+		&cli.BoolFlag{
+			Name: "dry-run",
+			Usage: "Print the fully resolved request together with the grant type and issuer instead of " +
+				"requesting a mytoken; the server is not contacted",
+			Destination: &mtCommand.DryRun,
+		},
 	)
 	app.Commands = append(
 		app.Commands, &cli.Command{
@@ -545,6 +710,9 @@ func init() {
 }
 
 func obtainMTCmd(ctx context.Context, cmd *cli.Command) error {
+	if mtCommand.DryRun {
+		return printMTDryRun(ctx, cmd)
+	}
 	mt, err := obtainMT(ctx, cmd)
 	if err != nil {
 		return err
@@ -564,15 +732,10 @@ func obtainMT(ctx context.Context, cmd *cli.Command) (string, error) {
 		}
 		return mt, nil
 	}
-	req, err := mtCommand.Request(ctx, cmd)
+	req, err := mtCommand.finalRequest(ctx, cmd)
 	if err != nil {
 		return "", err
 	}
-	prefix := config.Get().TokenNamePrefix
-	if req.Name != "" && prefix != "" {
-		req.Name = fmt.Sprintf("%s:%s", prefix, req.Name)
-	}
-	req.ApplicationName = fmt.Sprintf("mytoken client on %s", config.Get().Hostname)
 	if ssh := mtCommand.SSH(); ssh != "" {
 		req.GrantType = api.GrantTypeSSH
 		mt, err := doSSHReturnOutput(ssh, api.SSHRequestMytoken, req)
@@ -608,6 +771,15 @@ func obtainMT(ctx context.Context, cmd *cli.Command) (string, error) {
 	if err != nil {
 		return "", err
 	}
+	switch mtCommand.OIDCFlow {
+	case oidcFlowPolling:
+	case oidcFlowLoopback:
+		return obtainMTLoopback(ctx, mytoken, *req, mtCommand.OIDCTimeout)
+	default:
+		return "", fmt.Errorf(
+			"unknown oidc flow '%s'; must be one of %s", mtCommand.OIDCFlow, strings.Join(oidcFlows, ", "),
+		)
+	}
 	callbacks := mytokenlib.PollingCallbacks{
 		Init: func(authorizationURL string) error {
 			_, _ = fmt.Fprintln(
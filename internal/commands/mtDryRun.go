@@ -0,0 +1,180 @@
+package commands
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/oidc-mytoken/api/v0"
+	"github.com/oidc-mytoken/utils/utils/jwtutils"
+	log "github.com/sirupsen/logrus"
+	"github.com/urfave/cli/v3"
+
+	"github.com/oidc-mytoken/client/internal/config"
+	"github.com/oidc-mytoken/client/internal/utils/tablewriter"
+)
+
+const (
+	redactedMytoken           = "<redacted>"
+	dryRunLoopbackRedirectURI = "http://127.0.0.1:<port>/callback?state=<state>"
+)
+
+// mtDryRun describes the request that would be sent to obtain a mytoken
+type mtDryRun struct {
+	GrantType     string `json:"grant_type"`
+	MytokenServer string `json:"mytoken_server"`
+	SSH           string `json:"ssh,omitempty"`
+	Request       any    `json:"request"`
+}
+
+// printMTDryRun prints the request that obtainMT would send, without contacting the server
+func printMTDryRun(ctx context.Context, cmd *cli.Command) error {
+	d, err := resolveMTDryRun(ctx, cmd)
+	if err != nil {
+		return err
+	}
+	if tablewriter.Structured() {
+		return tablewriter.PrintData(d)
+	}
+	rows, err := d.tableRows()
+	if err != nil {
+		return err
+	}
+	tablewriter.PrintTableData(rows)
+	return nil
+}
+
+func resolveMTDryRun(ctx context.Context, cmd *cli.Command) (*mtDryRun, error) {
+	d := &mtDryRun{
+		MytokenServer: config.Get().URL,
+	}
+	if mtCommand.TransferCode != "" {
+		d.GrantType = api.GrantTypeTransferCode
+		d.Request = api.ExchangeTransferCodeRequest{
+			GrantType:    api.GrantTypeTransferCode,
+			TransferCode: mtCommand.TransferCode,
+		}
+		return d, nil
+	}
+	req, err := mtCommand.finalRequest(ctx, cmd)
+	if err != nil {
+		return nil, err
+	}
+	if ssh := mtCommand.SSH(); ssh != "" {
+		req.GrantType = api.GrantTypeSSH
+		d.GrantType = req.GrantType
+		d.MytokenServer = ""
+		d.SSH = ssh
+		d.Request = req
+		return d, nil
+	}
+	if mtGrant := mtCommand._getToken(); mtGrant != "" && !mtCommand.UseOIDCFlow {
+		req.GrantType = api.GrantTypeMytoken
+		d.GrantType = req.GrantType
+		if jwtutils.IsJWT(mtGrant) {
+			if iss, ok := jwtutils.GetStringFromJWT(log.StandardLogger(), mtGrant, "iss"); ok {
+				d.MytokenServer = iss
+			}
+		}
+		d.Request = api.MytokenFromMytokenRequest{
+			GeneralMytokenRequest:        *req,
+			Mytoken:                      redactedMytoken,
+			FailOnRestrictionsNotTighter: true,
+		}
+		return d, nil
+	}
+	if err = mtCommand.parseProviderOpt(); err != nil {
+		return nil, err
+	}
+	req.GrantType = api.GrantTypeOIDCFlow
+	d.GrantType = req.GrantType
+	flowReq := api.AuthCodeFlowRequest{
+		OIDCFlowRequest: api.OIDCFlowRequest{
+			GeneralMytokenRequest: *req,
+			OIDCFlow:              api.OIDCFlowAuthorizationCode,
+		},
+		ClientType: api.ClientTypeNative,
+	}
+	switch mtCommand.OIDCFlow {
+	case oidcFlowPolling:
+	case oidcFlowLoopback:
+		// the port and the state are chosen when the listener is started
+		flowReq.RedirectURI = dryRunLoopbackRedirectURI
+	default:
+		return nil, fmt.Errorf(
+			"unknown oidc flow '%s'; must be one of %s", mtCommand.OIDCFlow, strings.Join(oidcFlows, ", "),
+		)
+	}
+	d.Request = flowReq
+	return d, nil
+}
+
+// tableRows returns the dry run as field-value pairs; the top-level fields of the request are listed in their json
+// order, nested values are shown as compact json
+func (d mtDryRun) tableRows() ([]tablewriter.TableWriter, error) {
+	rows := []tablewriter.TableWriter{
+		tableDryRunField{"Grant Type", d.GrantType},
+	}
+	if d.MytokenServer != "" {
+		rows = append(rows, tableDryRunField{"Mytoken Server", d.MytokenServer})
+	}
+	if d.SSH != "" {
+		rows = append(rows, tableDryRunField{"SSH", d.SSH})
+	}
+	data, err := json.Marshal(d.Request)
+	if err != nil {
+		return nil, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	if _, err = dec.Token(); err != nil { // opening brace
+		return nil, err
+	}
+	for dec.More() {
+		key, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		var value json.RawMessage
+		if err = dec.Decode(&value); err != nil {
+			return nil, err
+		}
+		if key == "grant_type" || string(value) == `""` { // already listed or not set
+			continue
+		}
+		rows = append(rows, tableDryRunField{key.(string), dryRunValueString(value)})
+	}
+	return rows, nil
+}
+
+func dryRunValueString(value json.RawMessage) string {
+	var s string
+	if err := json.Unmarshal(value, &s); err == nil {
+		return s
+	}
+	var buf bytes.Buffer
+	if err := json.Compact(&buf, value); err != nil {
+		return string(value)
+	}
+	return strings.TrimSpace(buf.String())
+}
+
+type tableDryRunField struct {
+	Field string `json:"field"`
+	Value string `json:"value"`
+}
+
+func (tableDryRunField) TableGetHeader() []string {
+	return []string{
+		"Field",
+		"Value",
+	}
+}
+
+func (f tableDryRunField) TableGetRow() []string {
+	return []string{
+		f.Field,
+		f.Value,
+	}
+}
@@ -0,0 +1,126 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/oidc-mytoken/api/v0"
+	mytokenlib "github.com/oidc-mytoken/lib"
+	"github.com/pkg/errors"
+
+	"github.com/oidc-mytoken/client/internal/utils/loopback"
+)
+
+const (
+	oidcFlowPolling  = "polling"
+	oidcFlowLoopback = "loopback"
+)
+
+var oidcFlows = []string{
+	oidcFlowPolling,
+	oidcFlowLoopback,
+}
+
+// errorStrSlowDown is the error returned by the mytoken server if a client polls faster than the polling interval
+const errorStrSlowDown = "slow_down"
+
+// obtainMTLoopback obtains a mytoken through the polling flow of the mytoken server, where the browser is
+// redirected to a listener on the loopback interface at the end of the flow. The redirect only triggers the next
+// poll, so the mytoken is fetched immediately; it carries no authorization code and there is no PKCE, since the
+// mytoken server does the code exchange with the provider itself. If the redirect does not arrive (e.g. because the
+// flow was completed on another device), the mytoken is still obtained by polling.
+func obtainMTLoopback(
+	ctx context.Context, mytoken *mytokenlib.MytokenServer, req api.GeneralMytokenRequest, timeout time.Duration,
+) (string, error) {
+	listener, err := loopback.Listen()
+	if err != nil {
+		return "", err
+	}
+	defer func() {
+		_ = listener.Close()
+	}()
+	req.GrantType = api.GrantTypeOIDCFlow
+	flowReq := api.AuthCodeFlowRequest{
+		OIDCFlowRequest: api.OIDCFlowRequest{
+			GeneralMytokenRequest: req,
+			OIDCFlow:              api.OIDCFlowAuthorizationCode,
+		},
+		ClientType:  api.ClientTypeNative,
+		RedirectURI: listener.RedirectURI(),
+	}
+	var flowRes api.AuthCodeFlowResponse
+	if err = mytoken.Mytoken.DoHTTPRequest("POST", flowReq, &flowRes); err != nil {
+		return "", err
+	}
+
+	if expiresIn := time.Duration(flowRes.PollingCodeExpiresIn) * time.Second; expiresIn > 0 && expiresIn < timeout {
+		timeout = expiresIn
+	}
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	_, _ = fmt.Fprintln(os.Stderr, "Opening the following url in your browser; if it does not open, please visit it:")
+	_, _ = fmt.Fprintln(os.Stderr)
+	_, _ = fmt.Fprintln(os.Stderr, flowRes.ConsentURI)
+	_, _ = fmt.Fprintln(os.Stderr)
+	if err = loopback.OpenBrowser(flowRes.ConsentURI); err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "Could not open a browser: %s\n", err)
+	}
+	_, _ = fmt.Fprintf(os.Stderr, "Waiting for the authorization to complete (timeout %s) ...\n", timeout)
+
+	interval := time.Duration(flowRes.PollingInterval) * time.Second
+	if interval <= 0 {
+		interval = 5 * time.Second
+	}
+	tick := time.NewTicker(interval)
+	defer tick.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				return "", errors.Errorf("authorization was not completed within %s", timeout)
+			}
+			return "", errors.New("authorization aborted")
+		case res := <-listener.Results():
+			if err = res.Err(); err != nil {
+				return "", errors.Wrap(err, "authorization failed")
+			}
+		case <-tick.C:
+		}
+		res, err := mytoken.Mytoken.APIPollOnce(flowRes.PollingCode)
+		if err != nil {
+			if !isSlowDown(err) {
+				return "", err
+			}
+			interval += 5 * time.Second
+			tick.Reset(interval)
+			continue
+		}
+		if res == nil {
+			continue
+		}
+		if res.Mytoken == "" {
+			return "", errors.New("server returned empty mytoken")
+		}
+		_, _ = fmt.Fprintln(os.Stderr, "success")
+		return res.Mytoken, nil
+	}
+}
+
+// isSlowDown checks if the mytoken server asked to poll less frequently; the polling interval must then be increased
+// by 5 seconds
+func isSlowDown(err error) bool {
+	var myErr mytokenlib.MytokenError
+	if !errors.As(err, &myErr) {
+		return false
+	}
+	e, _, _ := strings.Cut(myErr.Error(), ":")
+	return e == errorStrSlowDown
+}
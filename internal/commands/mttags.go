@@ -23,10 +23,11 @@ func init() {
 		Usage: "Manage tags on mytokens",
 		Commands: []*cli.Command{
 			{
-				Name:      "add",
-				Usage:     "Add a tag to a mytoken",
-				ArgsUsage: "<tag>",
-				Action:    addMTTag,
+				Name:          "add",
+				Usage:         "Add a tag to a mytoken",
+				ArgsUsage:     "<tag>",
+				Action:        addMTTag,
+				ShellComplete: shellComplete(completeTags),
 				Flags: append(
 					getMTFlags(),
 					&cli.StringFlag{
@@ -47,9 +48,10 @@ func init() {
 					"rm",
 					"delete",
 				},
-				Usage:     "Remove a tag from a mytoken",
-				ArgsUsage: "<tag>",
-				Action:    removeMTTag,
+				Usage:         "Remove a tag from a mytoken",
+				ArgsUsage:     "<tag>",
+				Action:        removeMTTag,
+				ShellComplete: shellComplete(completeTags),
 				Flags: append(
 					getMTFlags(),
 					&cli.StringFlag{
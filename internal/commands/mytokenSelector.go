@@ -0,0 +1,282 @@
+package commands
+
+import (
+	"net"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/oidc-mytoken/api/v0"
+	"github.com/oidc-mytoken/utils/utils/duration"
+	"github.com/pkg/errors"
+	"github.com/urfave/cli/v3"
+
+	"github.com/oidc-mytoken/client/internal/utils/timeexpr"
+)
+
+// mytokenSelectorOptions holds the options to select mytokens from the list of mytokens
+type mytokenSelectorOptions struct {
+	Tags          []string
+	Name          string
+	Expired       bool
+	Active        bool
+	ExpiresWithin string
+	CreatedAfter  string
+	CreatedBefore string
+	NeverExpires  bool
+	IPs           []string
+}
+
+func getMytokenSelectorFlags(opts *mytokenSelectorOptions) []cli.Flag {
+	return []cli.Flag{
+		&cli.StringSliceFlag{
+			Name:        "tag",
+			Usage:       "Only select mytokens with this `TAG`; can be used multiple times to select any of the tags",
+			Destination: &opts.Tags,
+		},
+		&cli.StringFlag{
+			Name: "name",
+			Usage: "Only select mytokens whose name matches `PATTERN`; PATTERN is a case-insensitive glob " +
+				"(e.g. 'ci-*') or a regular expression enclosed in slashes (e.g. '/^ci-[0-9]+$/')",
+			Destination: &opts.Name,
+		},
+		&cli.BoolFlag{
+			Name:        "expired",
+			Usage:       "Only select expired mytokens",
+			Destination: &opts.Expired,
+		},
+		&cli.BoolFlag{
+			Name:        "active",
+			Usage:       "Only select mytokens that are not expired",
+			Destination: &opts.Active,
+		},
+		&cli.StringFlag{
+			Name:        "expires-within",
+			Usage:       "Only select mytokens that are not expired, but expire within `DURATION`, e.g. '7d'",
+			Destination: &opts.ExpiresWithin,
+		},
+		&cli.StringFlag{
+			Name:        "created-after",
+			Usage:       "Only select mytokens created after `TIME`. " + timeexpr.PastHelp,
+			Destination: &opts.CreatedAfter,
+		},
+		&cli.StringFlag{
+			Name:        "created-before",
+			Usage:       "Only select mytokens created before `TIME`. " + timeexpr.PastHelp,
+			Destination: &opts.CreatedBefore,
+		},
+		&cli.BoolFlag{
+			Name:        "never-expires",
+			Usage:       "Only select mytokens that do not expire",
+			Destination: &opts.NeverExpires,
+		},
+		&cli.StringSliceFlag{
+			Name: "ip",
+			Usage: "Only select mytokens created from this `IP` address or network (CIDR); " +
+				"can be used multiple times",
+			Destination: &opts.IPs,
+		},
+	}
+}
+
+// mytokenSelector decides if a mytoken is selected; all set criteria must be fulfilled
+type mytokenSelector struct {
+	tags          map[string]bool
+	name          *regexp.Regexp
+	expired       bool
+	active        bool
+	expiresBefore int64
+	createdAfter  int64
+	createdBefore int64
+	neverExpires  bool
+	ips           *ipMatcher
+	now           int64
+}
+
+func (opts mytokenSelectorOptions) selector() (*mytokenSelector, error) {
+	now := time.Now()
+	s := &mytokenSelector{
+		expired:      opts.Expired,
+		active:       opts.Active,
+		neverExpires: opts.NeverExpires,
+		now:          now.Unix(),
+	}
+	if opts.Expired && opts.Active {
+		return nil, errors.New("--expired and --active cannot be used together")
+	}
+	if len(opts.Tags) > 0 {
+		s.tags = make(map[string]bool, len(opts.Tags))
+		for _, t := range opts.Tags {
+			s.tags[strings.ToLower(strings.TrimSpace(t))] = true
+		}
+	}
+	if opts.Name != "" {
+		var err error
+		if s.name, err = namePattern(opts.Name); err != nil {
+			return nil, errors.Wrap(err, "invalid value for --name")
+		}
+	}
+	if opts.ExpiresWithin != "" {
+		d, err := duration.ParseDuration(opts.ExpiresWithin)
+		if err != nil {
+			return nil, errors.Errorf("invalid value for --expires-within: '%s' is not a duration", opts.ExpiresWithin)
+		}
+		s.expiresBefore = now.Add(d).Unix()
+	}
+	if opts.CreatedAfter != "" {
+		var err error
+		if s.createdAfter, err = timeexpr.ParsePast(opts.CreatedAfter, now); err != nil {
+			return nil, errors.Wrap(err, "invalid value for --created-after")
+		}
+	}
+	if opts.CreatedBefore != "" {
+		var err error
+		if s.createdBefore, err = timeexpr.ParsePast(opts.CreatedBefore, now); err != nil {
+			return nil, errors.Wrap(err, "invalid value for --created-before")
+		}
+	}
+	if len(opts.IPs) > 0 {
+		var err error
+		if s.ips, err = newIPMatcher(opts.IPs); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// namePattern compiles a name pattern; patterns enclosed in slashes are regular expressions, others are globs
+func namePattern(pattern string) (*regexp.Regexp, error) {
+	if len(pattern) > 1 && strings.HasPrefix(pattern, "/") && strings.HasSuffix(pattern, "/") {
+		return regexp.Compile(pattern[1 : len(pattern)-1])
+	}
+	var b strings.Builder
+	b.WriteString("(?i)^")
+	for _, c := range pattern {
+		switch c {
+		case '*':
+			b.WriteString(".*")
+		case '?':
+			b.WriteString(".")
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	b.WriteString("$")
+	return regexp.Compile(b.String())
+}
+
+// empty checks if no criteria are set, i.e. all mytokens are selected
+func (s *mytokenSelector) empty() bool {
+	return s.tags == nil && s.name == nil && !s.expired && !s.active && !s.neverExpires && s.expiresBefore == 0 &&
+		s.createdAfter == 0 && s.createdBefore == 0 && s.ips == nil
+}
+
+func (s *mytokenSelector) match(e api.MytokenEntry) bool {
+	expired := e.ExpiresAt > 0 && e.ExpiresAt < s.now
+	if s.expired && !expired {
+		return false
+	}
+	if s.active && expired {
+		return false
+	}
+	if s.neverExpires && e.ExpiresAt != 0 {
+		return false
+	}
+	if s.expiresBefore != 0 && (e.ExpiresAt == 0 || expired || e.ExpiresAt > s.expiresBefore) {
+		return false
+	}
+	if s.createdAfter != 0 && e.CreatedAt <= s.createdAfter {
+		return false
+	}
+	if s.createdBefore != 0 && e.CreatedAt >= s.createdBefore {
+		return false
+	}
+	if s.name != nil && !s.name.MatchString(e.Name) {
+		return false
+	}
+	if s.ips != nil && !s.ips.match(e.IP) {
+		return false
+	}
+	if s.tags != nil {
+		found := false
+		for _, t := range e.Tags {
+			if s.tags[strings.ToLower(string(t.Tag))] {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// filterMytokenTree returns the part of the tree that contains the selected mytokens; parents of selected mytokens
+// are kept to preserve the hierarchy and are returned in the second return value, keyed by their MOM-ID
+func (s *mytokenSelector) filterMytokenTree(tree []api.MytokenEntryTree) ([]api.MytokenEntryTree, map[string]bool) {
+	parents := map[string]bool{}
+	var filter func(entries []api.MytokenEntryTree) []api.MytokenEntryTree
+	filter = func(entries []api.MytokenEntryTree) []api.MytokenEntryTree {
+		var result []api.MytokenEntryTree
+		for _, e := range entries {
+			children := filter(e.Children)
+			matched := s.match(e.Token)
+			if !matched && len(children) == 0 {
+				continue
+			}
+			if !matched {
+				parents[e.Token.MOMID] = true
+			}
+			result = append(
+				result, api.MytokenEntryTree{
+					Token:    e.Token,
+					Children: children,
+				},
+			)
+		}
+		return result
+	}
+	return filter(tree), parents
+}
+
+// ipMatcher matches ip addresses against a list of addresses and networks
+type ipMatcher struct {
+	ips      []string
+	networks []*net.IPNet
+}
+
+func newIPMatcher(values []string) (*ipMatcher, error) {
+	m := &ipMatcher{}
+	for _, ip := range values {
+		ip = strings.TrimSpace(ip)
+		if strings.Contains(ip, "/") {
+			_, network, err := net.ParseCIDR(ip)
+			if err != nil {
+				return nil, errors.Errorf("invalid network '%s'", ip)
+			}
+			m.networks = append(m.networks, network)
+			continue
+		}
+		m.ips = append(m.ips, ip)
+	}
+	return m, nil
+}
+
+func (m *ipMatcher) match(ip string) bool {
+	for _, i := range m.ips {
+		if i == ip {
+			return true
+		}
+	}
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return false
+	}
+	for _, n := range m.networks {
+		if n.Contains(parsed) {
+			return true
+		}
+	}
+	return false
+}
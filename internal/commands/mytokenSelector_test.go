@@ -0,0 +1,273 @@
+package commands
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/oidc-mytoken/api/v0"
+)
+
+const testNow = 1772620200 // 2026-03-04 10:30:00 UTC
+
+func testEntry(momID, name string, created, expires int64, ip string, tags ...string) api.MytokenEntry {
+	e := api.MytokenEntry{
+		MOMID:     momID,
+		Name:      name,
+		CreatedAt: created,
+		ExpiresAt: expires,
+	}
+	e.IP = ip
+	for _, t := range tags {
+		e.Tags = append(e.Tags, api.MTTagInfo{TagInfo: api.TagInfo{Tag: api.Tag(t)}})
+	}
+	return e
+}
+
+func TestNamePattern(t *testing.T) {
+	tests := []struct {
+		pattern string
+		name    string
+		match   bool
+	}{
+		{pattern: "laptop", name: "laptop", match: true},
+		{pattern: "laptop", name: "my laptop", match: false},
+		{pattern: "Laptop", name: "laptop", match: true},
+		{pattern: "ci-*", name: "ci-runner-1", match: true},
+		{pattern: "ci-*", name: "my ci-runner", match: false},
+		{pattern: "ci-?", name: "ci-1", match: true},
+		{pattern: "ci-?", name: "ci-12", match: false},
+		{pattern: "a.b", name: "axb", match: false},
+		{pattern: "a+b", name: "a+b", match: true},
+		{pattern: "/^ci-[0-9]+$/", name: "ci-12", match: true},
+		{pattern: "/^ci-[0-9]+$/", name: "ci-x", match: false},
+		{pattern: "/run/", name: "ci-runner", match: true},
+		{pattern: "/", name: "/", match: true},
+	}
+	for _, test := range tests {
+		re, err := namePattern(test.pattern)
+		if err != nil {
+			t.Fatalf("'%s': unexpected error: %s", test.pattern, err)
+		}
+		if got := re.MatchString(test.name); got != test.match {
+			t.Errorf("'%s' on '%s': expected %t, got %t", test.pattern, test.name, test.match, got)
+		}
+	}
+	if _, err := namePattern("/[/"); err == nil {
+		t.Error("expected an error for an invalid regular expression")
+	}
+}
+
+func TestMytokenSelectorOptionErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		opts mytokenSelectorOptions
+	}{
+		{name: "expired and active", opts: mytokenSelectorOptions{Expired: true, Active: true}},
+		{name: "invalid regex", opts: mytokenSelectorOptions{Name: "/(/"}},
+		{name: "invalid duration", opts: mytokenSelectorOptions{ExpiresWithin: "soon"}},
+		{name: "invalid created after", opts: mytokenSelectorOptions{CreatedAfter: "yesterday-ish"}},
+		{name: "invalid created before", opts: mytokenSelectorOptions{CreatedBefore: "x"}},
+		{name: "invalid network", opts: mytokenSelectorOptions{IPs: []string{"10.0.0.0/33"}}},
+	}
+	for _, test := range tests {
+		if _, err := test.opts.selector(); err == nil {
+			t.Errorf("%s: expected an error", test.name)
+		}
+	}
+	s, err := mytokenSelectorOptions{}.selector()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !s.empty() {
+		t.Error("expected an empty selector without options")
+	}
+}
+
+func TestMytokenSelectorMatch(t *testing.T) {
+	entries := map[string]api.MytokenEntry{
+		"ci":      testEntry("1", "ci-runner", testNow-10*86400, testNow+86400, "10.0.0.5", "CI", "prod"),
+		"laptop":  testEntry("2", "laptop", testNow-86400, 0, "192.168.1.2", "personal"),
+		"expired": testEntry("3", "old", testNow-100*86400, testNow-86400, "2001:db8::1"),
+		"later":   testEntry("4", "Later", testNow-3600, testNow+30*86400, "10.1.0.1"),
+	}
+	tests := []struct {
+		name     string
+		selector mytokenSelector
+		want     []string
+	}{
+		{name: "empty", want: []string{"ci", "expired", "later", "laptop"}},
+		{name: "tag", selector: mytokenSelector{tags: map[string]bool{"ci": true}}, want: []string{"ci"}},
+		{
+			name:     "any tag",
+			selector: mytokenSelector{tags: map[string]bool{"personal": true, "prod": true}},
+			want:     []string{"ci", "laptop"},
+		},
+		{name: "expired", selector: mytokenSelector{expired: true}, want: []string{"expired"}},
+		{name: "active", selector: mytokenSelector{active: true}, want: []string{"ci", "later", "laptop"}},
+		{name: "never expires", selector: mytokenSelector{neverExpires: true}, want: []string{"laptop"}},
+		{
+			name:     "expires within",
+			selector: mytokenSelector{expiresBefore: testNow + 7*86400},
+			want:     []string{"ci"},
+		},
+		{
+			name:     "created after",
+			selector: mytokenSelector{createdAfter: testNow - 2*86400},
+			want:     []string{"later", "laptop"},
+		},
+		{
+			name:     "created before",
+			selector: mytokenSelector{createdBefore: testNow - 2*86400},
+			want:     []string{"ci", "expired"},
+		},
+		{
+			name:     "ip",
+			selector: mytokenSelector{ips: &ipMatcher{ips: []string{"192.168.1.2"}}},
+			want:     []string{"laptop"},
+		},
+		{
+			name:     "combined",
+			selector: mytokenSelector{active: true, createdAfter: testNow - 2*86400, tags: map[string]bool{"ci": true}},
+		},
+	}
+	for _, test := range tests {
+		t.Run(
+			test.name, func(t *testing.T) {
+				s := test.selector
+				s.now = testNow
+				var got []string
+				for _, k := range []string{"ci", "expired", "later", "laptop"} {
+					if s.match(entries[k]) {
+						got = append(got, k)
+					}
+				}
+				if !reflect.DeepEqual(got, test.want) {
+					t.Errorf("expected %v, got %v", test.want, got)
+				}
+			},
+		)
+	}
+}
+
+func TestIPMatcher(t *testing.T) {
+	m, err := newIPMatcher([]string{"192.168.1.2", " 10.0.0.0/8 ", "2001:db8::/32"})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	tests := []struct {
+		ip    string
+		match bool
+	}{
+		{ip: "192.168.1.2", match: true},
+		{ip: "192.168.1.3", match: false},
+		{ip: "10.20.30.40", match: true},
+		{ip: "11.0.0.1", match: false},
+		{ip: "2001:db8::1", match: true},
+		{ip: "2001:db9::1", match: false},
+		{ip: "", match: false},
+		{ip: "not an ip", match: false},
+	}
+	for _, test := range tests {
+		if got := m.match(test.ip); got != test.match {
+			t.Errorf("'%s': expected %t, got %t", test.ip, test.match, got)
+		}
+	}
+}
+
+func TestFilterMytokenTree(t *testing.T) {
+	tree := []api.MytokenEntryTree{
+		{
+			Token: testEntry("root", "root", 1, 0, ""),
+			Children: []api.MytokenEntryTree{
+				{Token: testEntry("child", "ci-child", 2, 0, "")},
+				{
+					Token:    testEntry("other", "other", 3, 0, ""),
+					Children: []api.MytokenEntryTree{{Token: testEntry("grandchild", "ci-grandchild", 4, 0, "")}},
+				},
+				{Token: testEntry("unrelated", "unrelated", 5, 0, "")},
+			},
+		},
+		{Token: testEntry("ci", "ci-top", 6, 0, "")},
+		{Token: testEntry("none", "none", 7, 0, "")},
+	}
+	name, err := namePattern("ci-*")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	s := &mytokenSelector{
+		name: name,
+		now:  testNow,
+	}
+	filtered, parents := s.filterMytokenTree(tree)
+	if want := map[string]bool{"root": true, "other": true}; !reflect.DeepEqual(parents, want) {
+		t.Errorf("expected parents %v, got %v", want, parents)
+	}
+	var got []string
+	var walk func(entries []api.MytokenEntryTree, prefix string)
+	walk = func(entries []api.MytokenEntryTree, prefix string) {
+		for _, e := range entries {
+			got = append(got, prefix+e.Token.MOMID)
+			walk(e.Children, prefix+e.Token.MOMID+"/")
+		}
+	}
+	walk(filtered, "")
+	want := []string{"root", "root/child", "root/other", "root/other/grandchild", "ci"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected tree %v, got %v", want, got)
+	}
+}
+
+func TestCheckMytokenSortField(t *testing.T) {
+	for _, field := range []string{"", mytokenSortCreated, mytokenSortExpires, mytokenSortName} {
+		if err := checkMytokenSortField(field); err != nil {
+			t.Errorf("'%s': unexpected error: %s", field, err)
+		}
+	}
+	for _, field := range []string{"Name", "expiration", "created_at"} {
+		if err := checkMytokenSortField(field); err == nil {
+			t.Errorf("'%s': expected an error", field)
+		}
+	}
+}
+
+func TestSortMytokenTree(t *testing.T) {
+	newTree := func() []api.MytokenEntryTree {
+		return []api.MytokenEntryTree{
+			{Token: testEntry("b", "beta", 3, 0, "")},
+			{
+				Token: testEntry("a", "Alpha", 2, 200, ""),
+				Children: []api.MytokenEntryTree{
+					{Token: testEntry("a2", "zeta", 5, 0, "")},
+					{Token: testEntry("a1", "eta", 4, 50, "")},
+				},
+			},
+			{Token: testEntry("c", "gamma", 1, 100, "")},
+			{Token: testEntry("d", "delta", 6, 0, "")},
+		}
+	}
+	tests := []struct {
+		field string
+		want  []string
+	}{
+		{field: "", want: []string{"b", "a", "a2", "a1", "c", "d"}},
+		{field: mytokenSortCreated, want: []string{"c", "a", "a1", "a2", "b", "d"}},
+		{field: mytokenSortExpires, want: []string{"c", "a", "a1", "a2", "b", "d"}},
+		{field: mytokenSortName, want: []string{"a", "a1", "a2", "b", "d", "c"}},
+	}
+	for _, test := range tests {
+		tree := newTree()
+		sortMytokenTree(tree, test.field)
+		var got []string
+		var walk func(entries []api.MytokenEntryTree)
+		walk = func(entries []api.MytokenEntryTree) {
+			for _, e := range entries {
+				got = append(got, e.Token.MOMID)
+				walk(e.Children)
+			}
+		}
+		walk(tree)
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("'%s': expected %v, got %v", test.field, test.want, got)
+		}
+	}
+}
@@ -2,7 +2,9 @@ package commands
 
 import (
 	"context"
+	"fmt"
 	"os"
+	"strings"
 
 	log "github.com/sirupsen/logrus"
 	"github.com/urfave/cli/v3"
@@ -10,6 +12,7 @@ import (
 	"github.com/oidc-mytoken/client/internal/config"
 	"github.com/oidc-mytoken/client/internal/model/version"
 	"github.com/oidc-mytoken/client/internal/utils/color"
+	"github.com/oidc-mytoken/client/internal/utils/tablewriter"
 )
 
 var app = &cli.Command{
@@ -27,6 +30,8 @@ var app = &cli.Command{
 
 var configFile string
 var mytokenURL string
+var contextName string
+var outputFormat string
 
 func init() {
 	cli.RootCommandHelpTemplate = `NAME:
@@ -80,6 +85,28 @@ CONTACT:
 			),
 			Destination: &mytokenURL,
 		},
+		&cli.StringFlag{
+			Name: "context",
+			Usage: "Use the settings of the context `NAME` from the config file instead of the current context; " +
+				"--url takes precedence over the context's instance",
+			Sources: cli.EnvVars(
+				"MYTOKEN_CONTEXT",
+			),
+			Destination: &contextName,
+		},
+		&cli.StringFlag{
+			Name: "output",
+			Usage: fmt.Sprintf(
+				"Print listings and token information in the given `FORMAT`; one of %s. "+
+					"Colors are disabled for all formats except table.",
+				strings.Join(tablewriter.Formats, ", "),
+			),
+			Sources: cli.EnvVars(
+				"MYTOKEN_OUTPUT",
+			),
+			Value:       tablewriter.FormatTable,
+			Destination: &outputFormat,
+		},
 		&cli.BoolFlag{
 			Name:  "no-color",
 			Usage: "Disable colored output",
@@ -91,10 +118,23 @@ CONTACT:
 		} else {
 			config.LoadDefault()
 		}
+		if cmd.IsSet("context") {
+			if err := config.UseContext(contextName); err != nil {
+				return ctx, err
+			}
+		} else if current := config.Get().CurrentContext; current != "" {
+			// A stale current context must not lock out all commands, including the ones to fix it
+			if err := config.UseContext(current); err != nil {
+				_, _ = fmt.Fprintf(os.Stderr, "Warning: %s, using the default settings\n", err)
+			}
+		}
 		if cmd.IsSet("url") {
 			config.SetURL(mytokenURL)
 		}
-		if cmd.IsSet("no-color") {
+		if err := tablewriter.SetFormat(outputFormat); err != nil {
+			return ctx, err
+		}
+		if cmd.IsSet("no-color") || tablewriter.MachineReadable() {
 			color.DisableColors()
 		}
 		return ctx, nil
@@ -103,6 +143,7 @@ CONTACT:
 
 // Parse parses the command line options and calls the specified command
 func Parse() {
+	setupShellCompletion(app)
 	if err := app.Run(context.Background(), os.Args); err != nil {
 		log.Fatal(err)
 	}
@@ -0,0 +1,269 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/Songmu/prompter"
+	"github.com/oidc-mytoken/api/v0"
+	"github.com/oidc-mytoken/utils/utils/duration"
+	"github.com/pkg/errors"
+	"github.com/urfave/cli/v3"
+
+	"github.com/oidc-mytoken/client/internal/config"
+	"github.com/oidc-mytoken/client/internal/utils/color"
+	"github.com/oidc-mytoken/client/internal/utils/tablewriter"
+	"github.com/oidc-mytoken/client/internal/utils/timeexpr"
+)
+
+var pruneOptions = struct {
+	MTOptions
+	UnusedFor  string
+	ReportOnly bool
+	Yes        bool
+}{}
+
+func init() {
+	app.Commands = append(
+		app.Commands, &cli.Command{
+			Name: "prune",
+			Usage: "Revokes mytokens that expired or were not used for a long time; " +
+				"the last use is determined from the event history",
+			Description: "A mytoken without events in its history counts as last used when it was created. " +
+				"Mytokens with subtokens are only pruned if all their subtokens are pruned as well; " +
+				"they are revoked together with their subtokens. The used mytoken is never pruned.",
+			Action: prune,
+			Flags: appendMTFlags(
+				&cli.StringFlag{
+					Name:        "unused-for",
+					Usage:       "Prune mytokens that were not used for `DURATION`",
+					Value:       "90d",
+					Destination: &pruneOptions.UnusedFor,
+				},
+				&cli.BoolFlag{
+					Name:        "report-only",
+					Usage:       "Only list the mytokens that would be pruned",
+					Destination: &pruneOptions.ReportOnly,
+				},
+				&cli.BoolFlag{
+					Name:        "yes",
+					Aliases:     []string{"y"},
+					Usage:       "Skip the confirmation prompt",
+					Destination: &pruneOptions.Yes,
+				},
+			),
+		},
+	)
+}
+
+// pruneCandidate is a mytoken that expired or was not used for a long time
+type pruneCandidate struct {
+	entry    api.MytokenEntry
+	lastUsed int64
+	reason   string
+}
+
+func prune(_ context.Context, _ *cli.Command) error {
+	unusedFor, err := duration.ParseDuration(pruneOptions.UnusedFor)
+	if err != nil {
+		return errors.Errorf("invalid value for --unused-for: '%s' is not a duration", pruneOptions.UnusedFor)
+	}
+	ssh := pruneOptions.SSH()
+	var mToken, ownMOMID string
+	var tree []api.MytokenEntryTree
+	if ssh != "" {
+		res, err := doSSHParseJSON[api.TokeninfoListResponse](ssh, api.SSHRequestTokenInfoListMytokens, nil)
+		if err != nil {
+			return err
+		}
+		tree = res.Tokens
+		introspection, err := doSSHParseJSON[api.TokeninfoIntrospectResponse](
+			ssh, api.SSHRequestTokenInfoIntrospect, nil,
+		)
+		if err != nil {
+			return errors.Wrap(err, "could not introspect the used mytoken")
+		}
+		ownMOMID = introspection.MOMID
+	} else {
+		mToken = pruneOptions.MustGetToken()
+		mytoken := config.Get().Mytoken()
+		res, err := mytoken.Tokeninfo.APIListMytokens(mToken)
+		if err != nil {
+			return err
+		}
+		if res.TokenUpdate != nil {
+			updateMytoken(res.TokenUpdate.Mytoken)
+			mToken = res.TokenUpdate.Mytoken
+		}
+		tree = res.Tokens
+		introspection, err := mytoken.Tokeninfo.Introspect(mToken)
+		if err != nil {
+			return errors.Wrap(err, "could not introspect the used mytoken")
+		}
+		ownMOMID = introspection.MOMID
+	}
+
+	var momIDs []string
+	var collect func(entries []api.MytokenEntryTree)
+	collect = func(entries []api.MytokenEntryTree) {
+		for _, e := range entries {
+			momIDs = append(momIDs, e.Token.MOMID)
+			collect(e.Children)
+		}
+	}
+	collect(tree)
+	if len(momIDs) == 0 {
+		fmt.Println("There are no mytokens.")
+		return nil
+	}
+	lastUsed, mToken, err := lastMytokenUses(ssh, mToken, momIDs)
+	if err != nil {
+		return err
+	}
+
+	candidates := pruneCandidates(tree, lastUsed, ownMOMID, time.Now(), unusedFor)
+	if len(candidates) == 0 {
+		fmt.Println("No mytokens to prune.")
+		return nil
+	}
+	data := make([]tablewriter.TableWriter, len(candidates))
+	for i, c := range candidates {
+		data[i] = tablePruneCandidate(c)
+	}
+	if pruneOptions.ReportOnly {
+		tablewriter.PrintTableData(data)
+		return nil
+	}
+	fmt.Printf("The following %s will be revoked together with their subtokens:\n", numMytokens(len(candidates)))
+	tablewriter.PrintTableData(data)
+	if !pruneOptions.Yes && !prompter.YN(fmt.Sprintf("Revoke %s?", numMytokens(len(candidates))), false) {
+		fmt.Println("Cancelled.")
+		return nil
+	}
+	entries := make([]api.MytokenEntry, len(candidates))
+	for i, c := range candidates {
+		entries[i] = c.entry
+	}
+	return revokeMytokens(ssh, mToken, entries, true)
+}
+
+// pruneCandidates returns the mytokens in the tree that expired or were not used for unusedFor; lastUsed holds the
+// time of the latest event of each mytoken, keyed by MOM-ID, and the mytoken with ownMOMID is never a candidate. A
+// mytoken whose subtokens are all candidates is returned instead of them.
+func pruneCandidates(
+	tree []api.MytokenEntryTree, lastUsed map[string]int64, ownMOMID string, now time.Time, unusedFor time.Duration,
+) []pruneCandidate {
+	staleBefore := now.Add(-unusedFor).Unix()
+	var candidates []pruneCandidate
+	// prunable returns true if the mytoken and all its subtokens can be pruned; in that case the candidates added for
+	// the subtokens are replaced by the mytoken itself, since the subtokens are revoked together with it
+	var prunable func(e api.MytokenEntryTree) bool
+	prunable = func(e api.MytokenEntryTree) bool {
+		t := e.Token
+		last := lastUsed[t.MOMID]
+		if last == 0 {
+			last = t.CreatedAt
+		}
+		reason := ""
+		switch {
+		case t.MOMID == ownMOMID:
+		case t.ExpiresAt != 0 && t.ExpiresAt < now.Unix():
+			reason = "expired " + timeexpr.Relative(t.ExpiresAt, now)
+		case last < staleBefore:
+			reason = "not used for " + timeexpr.FormatDuration(now.Sub(time.Unix(last, 0)))
+		}
+		before := len(candidates)
+		all := reason != ""
+		for _, c := range e.Children {
+			if !prunable(c) {
+				all = false
+			}
+		}
+		if !all {
+			return false
+		}
+		candidates = append(
+			candidates[:before], pruneCandidate{
+				entry:    t,
+				lastUsed: last,
+				reason:   reason,
+			},
+		)
+		return true
+	}
+	for _, e := range tree {
+		prunable(e)
+	}
+	return candidates
+}
+
+// lastMytokenUses returns the time of the latest event of each of the passed mytokens, keyed by MOM-ID; it also
+// returns the mytoken to use for further requests, which changes if the mytoken was rotated
+func lastMytokenUses(ssh, mToken string, momIDs []string) (map[string]int64, string, error) {
+	var events []api.EventEntry
+	if ssh != "" {
+		req := api.TokenInfoRequest{MOMIDs: momIDs}
+		res, err := doSSHParseJSON[api.TokeninfoHistoryResponse](ssh, api.SSHRequestTokenInfoHistory, &req)
+		if err != nil {
+			return nil, mToken, err
+		}
+		events = res.Events
+	} else {
+		res, err := config.Get().Mytoken().Tokeninfo.APIHistory(mToken, momIDs...)
+		if err != nil {
+			return nil, mToken, errors.Wrap(err, "could not obtain the event history")
+		}
+		if res.TokenUpdate != nil {
+			updateMytoken(res.TokenUpdate.Mytoken)
+			mToken = res.TokenUpdate.Mytoken
+		}
+		events = res.Events
+	}
+	last := make(map[string]int64, len(momIDs))
+	for _, e := range events {
+		if e.Time > last[e.MOMID] {
+			last[e.MOMID] = e.Time
+		}
+	}
+	return last, mToken, nil
+}
+
+type tablePruneCandidate pruneCandidate
+
+func (tablePruneCandidate) TableGetHeader() []string {
+	return []string{
+		"MOM-ID",
+		"Name",
+		"Created",
+		"Last Used",
+		"Reason",
+	}
+}
+
+func (c tablePruneCandidate) TableGetRow() []string {
+	const timeFmt = "2006-01-02 15:04:05"
+	name := c.entry.Name
+	if name == "" {
+		name = color.Italic("unnamed token")
+	}
+	return []string{
+		c.entry.MOMID,
+		name,
+		time.Unix(c.entry.CreatedAt, 0).Format(timeFmt),
+		time.Unix(c.lastUsed, 0).Format(timeFmt),
+		c.reason,
+	}
+}
+
+func (c tablePruneCandidate) TableGetData() any {
+	return struct {
+		api.MytokenEntry
+		LastUsed int64  `json:"last_used"`
+		Reason   string `json:"reason"`
+	}{
+		MytokenEntry: c.entry,
+		LastUsed:     c.lastUsed,
+		Reason:       c.reason,
+	}
+}
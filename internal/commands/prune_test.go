@@ -0,0 +1,88 @@
+package commands
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/oidc-mytoken/api/v0"
+)
+
+func TestPruneCandidates(t *testing.T) {
+	const day = 86400
+	now := time.Unix(testNow, 0)
+	node := func(momID string, created, expires int64, children ...api.MytokenEntryTree) api.MytokenEntryTree {
+		return api.MytokenEntryTree{
+			Token:    testEntry(momID, momID, created, expires, ""),
+			Children: children,
+		}
+	}
+	fresh := func(momID string, children ...api.MytokenEntryTree) api.MytokenEntryTree {
+		return node(momID, testNow-day, 0, children...)
+	}
+	stale := func(momID string, children ...api.MytokenEntryTree) api.MytokenEntryTree {
+		return node(momID, testNow-100*day, 0, children...)
+	}
+	expired := func(momID string, children ...api.MytokenEntryTree) api.MytokenEntryTree {
+		return node(momID, testNow-day, testNow-day, children...)
+	}
+
+	tests := []struct {
+		name     string
+		tree     []api.MytokenEntryTree
+		lastUsed map[string]int64
+		own      string
+		want     []string
+	}{
+		{name: "empty"},
+		{name: "fresh", tree: []api.MytokenEntryTree{fresh("a")}},
+		{name: "expired", tree: []api.MytokenEntryTree{expired("a")}, want: []string{"a: expired 1d ago"}},
+		{name: "not used", tree: []api.MytokenEntryTree{stale("a")}, want: []string{"a: not used for 100d"}},
+		{
+			name:     "last use",
+			tree:     []api.MytokenEntryTree{stale("a"), stale("b")},
+			lastUsed: map[string]int64{"a": testNow - 2*day, "b": testNow - 91*day},
+			want:     []string{"b: not used for 91d"},
+		},
+		{
+			name: "own mytoken",
+			tree: []api.MytokenEntryTree{expired("a"), stale("b")},
+			own:  "a",
+			want: []string{"b: not used for 100d"},
+		},
+		{
+			name: "all subtokens prunable",
+			tree: []api.MytokenEntryTree{stale("a", expired("a1"), stale("a2", stale("a21")))},
+			want: []string{"a: not used for 100d"},
+		},
+		{
+			name: "fresh subtoken",
+			tree: []api.MytokenEntryTree{stale("a", expired("a1"), stale("a2", fresh("a21")), stale("a3"))},
+			want: []string{"a1: expired 1d ago", "a3: not used for 100d"},
+		},
+		{
+			name: "fresh parent",
+			tree: []api.MytokenEntryTree{fresh("a", stale("a1", expired("a11")), fresh("a2"))},
+			want: []string{"a1: not used for 100d"},
+		},
+		{
+			name: "own subtoken",
+			tree: []api.MytokenEntryTree{expired("a", stale("a1"), stale("a2"))},
+			own:  "a2",
+			want: []string{"a1: not used for 100d"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(
+			test.name, func(t *testing.T) {
+				var got []string
+				for _, c := range pruneCandidates(test.tree, test.lastUsed, test.own, now, 90*24*time.Hour) {
+					got = append(got, c.entry.MOMID+": "+c.reason)
+				}
+				if !reflect.DeepEqual(got, test.want) {
+					t.Errorf("expected %v, got %v", test.want, got)
+				}
+			},
+		)
+	}
+}
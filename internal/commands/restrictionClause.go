@@ -0,0 +1,95 @@
+package commands
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/oidc-mytoken/api/v0"
+	"github.com/oidc-mytoken/utils/utils"
+	"github.com/pkg/errors"
+)
+
+const restrictionClauseUsage = "Add a restriction clause given as 'KEY=VALUE;KEY=VALUE'; " +
+	"KEY can be profile, scope, aud, exp, nbf, host, geo-ip-allow, geo-ip-disallow, usages-at, and usages-other. " +
+	"Multiple values for a key are separated by spaces or given by repeating the key, e.g. " +
+	"'scope=storage.read;exp=+1d;usages-at=10'. Can be used multiple times; " +
+	"the clauses are added to the restrictions from --restrictions or the other restriction options."
+
+// parseRestrictionClauses parses restriction clauses given as 'KEY=VALUE;KEY=VALUE'
+func parseRestrictionClauses(clauses []string) (api.Restrictions, error) {
+	var restrictions api.Restrictions
+	for i, c := range clauses {
+		r, err := parseRestrictionClause(c)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid restriction clause %d '%s'", i+1, c)
+		}
+		restrictions = append(restrictions, r)
+	}
+	return restrictions, nil
+}
+
+func parseRestrictionClause(clause string) (*api.Restriction, error) {
+	r := &api.Restriction{}
+	var scopes []string
+	empty := true
+	for _, part := range strings.Split(clause, ";") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		key, value, found := strings.Cut(part, "=")
+		if !found {
+			return nil, errors.Errorf("'%s' is not of the form KEY=VALUE", part)
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		value = strings.TrimSpace(value)
+		if value == "" {
+			return nil, errors.Errorf("no value given for '%s'", key)
+		}
+		empty = false
+		var err error
+		switch key {
+		case "profile", "profiles", "include":
+			r.IncludedProfiles = append(r.IncludedProfiles, strings.Fields(value)...)
+		case "scope", "scopes":
+			scopes = append(scopes, strings.Fields(value)...)
+		case "aud", "audience", "audiences":
+			r.Audiences = append(r.Audiences, strings.Fields(value)...)
+		case "exp", "naf":
+			r.ExpiresAt, err = parseTimeOption(key, value)
+		case "nbf":
+			r.NotBefore, err = parseTimeOption(key, value)
+		case "host", "hosts", "ip", "ips", "ip-allow":
+			r.Hosts = append(r.Hosts, strings.Fields(value)...)
+		case "geo-ip-allow":
+			r.GeoIPAllow = append(r.GeoIPAllow, strings.Fields(value)...)
+		case "geo-ip-disallow":
+			r.GeoIPDisallow = append(r.GeoIPDisallow, strings.Fields(value)...)
+		case "usages-at":
+			r.UsagesAT, err = parseRestrictionClauseUsages(value)
+		case "usages-other":
+			r.UsagesOther, err = parseRestrictionClauseUsages(value)
+		default:
+			return nil, errors.Errorf("unknown key '%s'", key)
+		}
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid value for '%s'", key)
+		}
+	}
+	if empty {
+		return nil, errors.New("clause is empty")
+	}
+	r.Scope = strings.Join(scopes, " ")
+	return r, nil
+}
+
+func parseRestrictionClauseUsages(value string) (*int64, error) {
+	n, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return nil, errors.Errorf("'%s' is not a number", value)
+	}
+	if n < 0 {
+		return nil, errors.Errorf("'%s' must not be negative", value)
+	}
+	return utils.NewInt64(n), nil
+}
@@ -0,0 +1,118 @@
+package commands
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/oidc-mytoken/api/v0"
+	"github.com/oidc-mytoken/utils/utils"
+)
+
+func TestParseRestrictionClause(t *testing.T) {
+	tests := []struct {
+		name   string
+		clause string
+		want   api.Restriction
+	}{
+		{
+			name:   "single",
+			clause: "scope=storage.read",
+			want:   api.Restriction{Scope: "storage.read"},
+		},
+		{
+			name: "all keys",
+			clause: "profile=p1;scope=openid;aud=a1;exp=1800000000;nbf=1700000000;host=10.0.0.0/8;" +
+				"geo-ip-allow=de;geo-ip-disallow=us;usages-at=10;usages-other=0",
+			want: api.Restriction{
+				NotBefore:        1700000000,
+				ExpiresAt:        1800000000,
+				Scope:            "openid",
+				Audiences:        []string{"a1"},
+				Hosts:            []string{"10.0.0.0/8"},
+				GeoIPAllow:       []string{"de"},
+				GeoIPDisallow:    []string{"us"},
+				UsagesAT:         utils.NewInt64(10),
+				UsagesOther:      utils.NewInt64(0),
+				IncludedProfiles: api.IncludedProfiles{"p1"},
+			},
+		},
+		{
+			name:   "multiple values",
+			clause: "scope=openid profile;scopes=storage.read;aud=a1 a2;audience=a3",
+			want:   api.Restriction{Scope: "openid profile storage.read", Audiences: []string{"a1", "a2", "a3"}},
+		},
+		{
+			name:   "aliases",
+			clause: "include=p1;profiles=p2;ip=1.2.3.4;ips=5.6.7.8;ip-allow=::1;hosts=h;naf=1800000000",
+			want: api.Restriction{
+				ExpiresAt:        1800000000,
+				Hosts:            []string{"1.2.3.4", "5.6.7.8", "::1", "h"},
+				IncludedProfiles: api.IncludedProfiles{"p1", "p2"},
+			},
+		},
+		{
+			name:   "spacing and case",
+			clause: " ; SCOPE = openid ;  Usages-AT=3;; ",
+			want:   api.Restriction{Scope: "openid", UsagesAT: utils.NewInt64(3)},
+		},
+		{
+			name:   "value with equals sign",
+			clause: "aud=https://example.com/?a=b",
+			want:   api.Restriction{Audiences: []string{"https://example.com/?a=b"}},
+		},
+	}
+	for _, test := range tests {
+		t.Run(
+			test.name, func(t *testing.T) {
+				got, err := parseRestrictionClause(test.clause)
+				if err != nil {
+					t.Fatalf("unexpected error: %s", err)
+				}
+				if !reflect.DeepEqual(*got, test.want) {
+					t.Errorf("expected %+v, got %+v", test.want, *got)
+				}
+			},
+		)
+	}
+}
+
+func TestParseRestrictionClauseErrors(t *testing.T) {
+	tests := []struct {
+		clause string
+		err    string
+	}{
+		{clause: "", err: "clause is empty"},
+		{clause: " ; ;", err: "clause is empty"},
+		{clause: "scope", err: "'scope' is not of the form KEY=VALUE"},
+		{clause: "scope=", err: "no value given for 'scope'"},
+		{clause: "scope=openid;color=red", err: "unknown key 'color'"},
+		{clause: "usages-at=many", err: "invalid value for 'usages-at': 'many' is not a number"},
+		{clause: "usages-other=-1", err: "invalid value for 'usages-other': '-1' must not be negative"},
+		{clause: "exp=whenever", err: "invalid value for 'exp'"},
+	}
+	for _, test := range tests {
+		_, err := parseRestrictionClause(test.clause)
+		if err == nil {
+			t.Errorf("'%s': expected an error", test.clause)
+			continue
+		}
+		if !strings.HasPrefix(err.Error(), test.err) {
+			t.Errorf("'%s': expected error '%s', got '%s'", test.clause, test.err, err)
+		}
+	}
+}
+
+func TestParseRestrictionClauses(t *testing.T) {
+	restrictions, err := parseRestrictionClauses([]string{"scope=a", "scope=b;usages-at=1"})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(restrictions) != 2 || restrictions[0].Scope != "a" || restrictions[1].Scope != "b" {
+		t.Errorf("unexpected restrictions %+v", restrictions)
+	}
+	_, err = parseRestrictionClauses([]string{"scope=a", "scope"})
+	if want := "invalid restriction clause 2 'scope': "; err == nil || !strings.HasPrefix(err.Error(), want) {
+		t.Errorf("expected error starting with '%s', got '%v'", want, err)
+	}
+}
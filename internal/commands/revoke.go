@@ -14,6 +14,9 @@ var revokeCommand = struct {
 	MTOptions
 	Recursive bool
 	MOMID     string
+	Filter    bool
+	Yes       bool
+	Selector  mytokenSelectorOptions
 }{}
 
 func init() {
@@ -22,35 +25,57 @@ func init() {
 			Name:   "revoke",
 			Usage:  "Revokes a mytoken",
 			Action: revoke,
-			Flags: appendMTFlags(
-				&cli.BoolFlag{
-					Name:        "recursive",
-					Aliases:     []string{"r"},
-					Usage:       "If set, also all subtokens are revoked",
-					Destination: &revokeCommand.Recursive,
-				},
-				&cli.StringFlag{
-					Name: "mom-id",
-					Aliases: []string{
-						"MOM-ID",
-						"mom",
-						"MOM",
+			Flags: append(
+				appendMTFlags(
+					&cli.BoolFlag{
+						Name:        "recursive",
+						Aliases:     []string{"r"},
+						Usage:       "If set, also all subtokens are revoked",
+						Destination: &revokeCommand.Recursive,
 					},
-					Usage: fmt.Sprintf(
-						"If set, "+
-							"the mytoken with the passed mom id is revoked instead of the actual token.	"+
-							"This requires that the token linked to the mom id is either a child of the actual mytoken or"+
-							" the actual mytoken has the %s capability.", api.CapabilityRevokeAnyToken,
-					),
-					Sources:     cli.EnvVars("MOM_ID"),
-					Destination: &revokeCommand.MOMID,
-				},
+					&cli.StringFlag{
+						Name: "mom-id",
+						Aliases: []string{
+							"MOM-ID",
+							"mom",
+							"MOM",
+						},
+						Usage: fmt.Sprintf(
+							"If set, "+
+								"the mytoken with the passed mom id is revoked instead of the actual token.	"+
+								"This requires that the token linked to the mom id is either a child of the actual mytoken or"+
+								" the actual mytoken has the %s capability.", api.CapabilityRevokeAnyToken,
+						),
+						Sources:     cli.EnvVars("MOM_ID"),
+						Destination: &revokeCommand.MOMID,
+					},
+					&cli.BoolFlag{
+						Name: "filter",
+						Usage: "Revoke all mytokens selected by the selection options instead of a single mytoken; " +
+							"the selected mytokens are listed and must be confirmed before they are revoked",
+						Destination: &revokeCommand.Filter,
+					},
+					&cli.BoolFlag{
+						Name:        "yes",
+						Aliases:     []string{"y"},
+						Usage:       "Skip the confirmation prompt when revoking with --filter",
+						Destination: &revokeCommand.Yes,
+					},
+				),
+				getMytokenSelectorFlags(&revokeCommand.Selector)...,
 			),
 		},
 	)
 }
 
 func revoke(_ context.Context, _ *cli.Command) error {
+	selector, err := revokeCommand.Selector.selector()
+	if err != nil {
+		return err
+	}
+	if revokeCommand.Filter || !selector.empty() {
+		return revokeByFilter(selector)
+	}
 	if ssh := revokeCommand.SSH(); ssh != "" {
 		if revokeCommand.MOMID != "" {
 			req := api.RevocationRequest{
@@ -63,7 +88,6 @@ func revoke(_ context.Context, _ *cli.Command) error {
 	}
 	mToken := revokeCommand.MustGetToken()
 	mytoken := config.Get().Mytoken()
-	var err error
 	if revokeCommand.MOMID != "" {
 		err = mytoken.Revocation.RevokeID(revokeCommand.MOMID, mToken, "", revokeCommand.Recursive)
 	} else {
@@ -0,0 +1,277 @@
+package commands
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/Songmu/prompter"
+	"github.com/oidc-mytoken/api/v0"
+	"github.com/pkg/errors"
+
+	"github.com/oidc-mytoken/client/internal/config"
+	"github.com/oidc-mytoken/client/internal/utils/tablewriter"
+)
+
+// revocationCandidate is a mytoken selected for revocation
+type revocationCandidate struct {
+	entry     api.MytokenEntry
+	subtokens int
+}
+
+// selectRevocationCandidates returns the selected mytokens of a tree; when revoking recursively, mytokens below a
+// selected mytoken are omitted, since they are revoked together with it
+func selectRevocationCandidates(
+	tree []api.MytokenEntryTree, selector *mytokenSelector, recursive bool,
+) []revocationCandidate {
+	var candidates []revocationCandidate
+	var walk func(entries []api.MytokenEntryTree)
+	walk = func(entries []api.MytokenEntryTree) {
+		for _, e := range entries {
+			if selector.match(e.Token) {
+				candidates = append(
+					candidates, revocationCandidate{
+						entry:     e.Token,
+						subtokens: countSubtokens(e.Children),
+					},
+				)
+				if recursive {
+					continue
+				}
+			}
+			walk(e.Children)
+		}
+	}
+	walk(tree)
+	return candidates
+}
+
+// revokeUsedMytokenLast moves the candidate whose revocation also revokes the used mytoken to the end, since the
+// remaining revocations would fail otherwise; when revoking recursively, this is also a selected ancestor of the
+// used mytoken
+func revokeUsedMytokenLast(
+	candidates []revocationCandidate, tree []api.MytokenEntryTree, ownMOMID string, recursive bool,
+) []revocationCandidate {
+	if ownMOMID == "" {
+		return candidates
+	}
+	affecting := map[string]bool{ownMOMID: true}
+	if recursive {
+		for _, momID := range ancestorMOMIDs(tree, ownMOMID) {
+			affecting[momID] = true
+		}
+	}
+	var first, last []revocationCandidate
+	for _, c := range candidates {
+		if affecting[c.entry.MOMID] {
+			last = append(last, c)
+		} else {
+			first = append(first, c)
+		}
+	}
+	return append(first, last...)
+}
+
+// ancestorMOMIDs returns the MOM-IDs of the mytokens above the passed mytoken in the tree
+func ancestorMOMIDs(tree []api.MytokenEntryTree, momID string) []string {
+	for _, e := range tree {
+		if e.Token.MOMID == momID {
+			return []string{}
+		}
+		if ancestors := ancestorMOMIDs(e.Children, momID); ancestors != nil {
+			return append(ancestors, e.Token.MOMID)
+		}
+	}
+	return nil
+}
+
+// numMytokens returns the number of mytokens with the correct plural, e.g. '1 mytoken' or '3 mytokens'
+func numMytokens(n int) string {
+	if n == 1 {
+		return "1 mytoken"
+	}
+	return strconv.Itoa(n) + " mytokens"
+}
+
+func countSubtokens(tree []api.MytokenEntryTree) int {
+	n := len(tree)
+	for _, e := range tree {
+		n += countSubtokens(e.Children)
+	}
+	return n
+}
+
+func revokeByFilter(selector *mytokenSelector) error {
+	if !revokeCommand.Filter {
+		return errors.New("the selection options can only be used together with --filter")
+	}
+	if revokeCommand.MOMID != "" {
+		return errors.New("--filter and --mom-id cannot be used together")
+	}
+	if selector.empty() {
+		return errors.New("--filter requires at least one selection option, e.g. --tag or --name")
+	}
+
+	ssh := revokeCommand.SSH()
+	var mToken, ownMOMID string
+	var tree []api.MytokenEntryTree
+	if ssh != "" {
+		res, err := doSSHParseJSON[api.TokeninfoListResponse](ssh, api.SSHRequestTokenInfoListMytokens, nil)
+		if err != nil {
+			return err
+		}
+		tree = res.Tokens
+		// the used mytoken must be revoked last, otherwise the remaining revocations would fail
+		introspection, err := doSSHParseJSON[api.TokeninfoIntrospectResponse](
+			ssh, api.SSHRequestTokenInfoIntrospect, nil,
+		)
+		if err == nil {
+			ownMOMID = introspection.MOMID
+		}
+	} else {
+		mToken = revokeCommand.MustGetToken()
+		mytoken := config.Get().Mytoken()
+		res, err := mytoken.Tokeninfo.APIListMytokens(mToken)
+		if err != nil {
+			return err
+		}
+		if res.TokenUpdate != nil {
+			updateMytoken(res.TokenUpdate.Mytoken)
+			mToken = res.TokenUpdate.Mytoken
+		}
+		tree = res.Tokens
+		// the used mytoken must be revoked last, otherwise the remaining revocations would fail
+		if introspection, err := mytoken.Tokeninfo.Introspect(mToken); err == nil {
+			ownMOMID = introspection.MOMID
+		}
+	}
+
+	candidates := selectRevocationCandidates(tree, selector, revokeCommand.Recursive)
+	if len(candidates) == 0 {
+		fmt.Println("No mytokens match the selection.")
+		return nil
+	}
+	candidates = revokeUsedMytokenLast(candidates, tree, ownMOMID, revokeCommand.Recursive)
+
+	preview := make([]tablewriter.TableWriter, len(candidates))
+	for i, c := range candidates {
+		preview[i] = tableRevocationCandidate(c)
+	}
+	if revokeCommand.Recursive {
+		fmt.Printf("The following %s will be revoked together with their subtokens:\n", numMytokens(len(candidates)))
+	} else {
+		fmt.Printf("The following %s will be revoked; their subtokens are not revoked:\n", numMytokens(len(candidates)))
+	}
+	tablewriter.PrintTableData(preview)
+	if !revokeCommand.Yes && !prompter.YN(fmt.Sprintf("Revoke %s?", numMytokens(len(candidates))), false) {
+		fmt.Println("Cancelled.")
+		return nil
+	}
+
+	entries := make([]api.MytokenEntry, len(candidates))
+	for i, c := range candidates {
+		entries[i] = c.entry
+	}
+	return revokeMytokens(ssh, mToken, entries, revokeCommand.Recursive)
+}
+
+// revokeMytokens revokes the passed mytokens by their MOM-ID and prints the result for each of them; it returns an
+// error if any revocation failed
+func revokeMytokens(ssh, mToken string, entries []api.MytokenEntry, recursive bool) error {
+	results := make([]tablewriter.TableWriter, len(entries))
+	failed := 0
+	for i, e := range entries {
+		var err error
+		if ssh != "" {
+			req := api.RevocationRequest{
+				MOMID:     e.MOMID,
+				Recursive: recursive,
+			}
+			_, err = doSSHReturnOutput(ssh, api.SSHRequestRevoke, &req)
+		} else {
+			err = config.Get().Mytoken().Revocation.RevokeID(e.MOMID, mToken, "", recursive)
+		}
+		if err != nil {
+			failed++
+		}
+		results[i] = tableRevocationResult{
+			entry: e,
+			err:   err,
+		}
+	}
+	fmt.Println()
+	tablewriter.PrintTableData(results)
+	if failed > 0 {
+		return errors.Errorf("%d of %d revocations failed", failed, len(entries))
+	}
+	return nil
+}
+
+type tableRevocationCandidate revocationCandidate
+
+func (c tableRevocationCandidate) TableGetHeader() []string {
+	return append(c.mytokenEntry().TableGetHeader(), "Subtokens")
+}
+
+func (c tableRevocationCandidate) TableGetRow() []string {
+	return append(c.mytokenEntry().TableGetRow(), strconv.Itoa(c.subtokens))
+}
+
+func (c tableRevocationCandidate) TableGetData() any {
+	return struct {
+		api.MytokenEntry
+		Subtokens int `json:"subtokens"`
+	}{
+		MytokenEntry: c.entry,
+		Subtokens:    c.subtokens,
+	}
+}
+
+func (c tableRevocationCandidate) mytokenEntry() tableMytokenEntry {
+	return tableMytokenEntry{
+		entry:        c.entry,
+		includeMOMID: true,
+	}
+}
+
+type tableRevocationResult struct {
+	entry api.MytokenEntry
+	err   error
+}
+
+func (tableRevocationResult) TableGetHeader() []string {
+	return []string{
+		"MOM-ID",
+		"Name",
+		"Result",
+	}
+}
+
+func (r tableRevocationResult) TableGetRow() []string {
+	result := "revoked"
+	if r.err != nil {
+		result = "failed: " + r.err.Error()
+	}
+	return []string{
+		r.entry.MOMID,
+		r.entry.Name,
+		result,
+	}
+}
+
+func (r tableRevocationResult) TableGetData() any {
+	errStr := ""
+	if r.err != nil {
+		errStr = r.err.Error()
+	}
+	return struct {
+		MOMID   string `json:"mom_id"`
+		Name    string `json:"name,omitempty"`
+		Revoked bool   `json:"revoked"`
+		Error   string `json:"error,omitempty"`
+	}{
+		MOMID:   r.entry.MOMID,
+		Name:    r.entry.Name,
+		Revoked: r.err == nil,
+		Error:   errStr,
+	}
+}
@@ -93,6 +93,17 @@ func listSSH(_ context.Context, _ *cli.Command) error {
 	if res.TokenUpdate != nil {
 		updateMytoken(res.TokenUpdate.Mytoken)
 	}
+	if tablewriter.Structured() {
+		return tablewriter.PrintData(
+			struct {
+				GrantEnabled bool             `json:"grant_enabled"`
+				SSHKeys      []api.SSHKeyInfo `json:"ssh_keys"`
+			}{
+				GrantEnabled: res.GrantEnabled,
+				SSHKeys:      res.SSHKeyInfo,
+			},
+		)
+	}
 	if res.GrantEnabled {
 		fmt.Println("SSH Grant Type is enabled.")
 	} else {
@@ -0,0 +1,222 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/oidc-mytoken/utils/utils/jwtutils"
+	log "github.com/sirupsen/logrus"
+	"github.com/urfave/cli/v3"
+
+	"github.com/oidc-mytoken/client/internal/config"
+	"github.com/oidc-mytoken/client/internal/store"
+	"github.com/oidc-mytoken/client/internal/utils/color"
+	"github.com/oidc-mytoken/client/internal/utils/tablewriter"
+)
+
+var storeOptions = struct {
+	MTOptions
+	Use bool
+}{}
+
+func init() {
+	app.Commands = append(
+		app.Commands, &cli.Command{
+			Name:  "store",
+			Usage: "Manage the local store of named mytokens",
+			Commands: []*cli.Command{
+				{
+					Name:      "add",
+					Usage:     "Add a mytoken to the store under the passed name",
+					ArgsUsage: "<name>",
+					Action:    addToStore,
+					Flags: append(
+						getMTFlags(),
+						&cli.BoolFlag{
+							Name:        "use",
+							Usage:       "Also make the added mytoken the current one",
+							Destination: &storeOptions.Use,
+						},
+					),
+				},
+				{
+					Name:    "list",
+					Aliases: []string{"ls"},
+					Usage:   "List the mytokens in the store",
+					Action:  listStore,
+				},
+				{
+					Name:          "use",
+					Usage:         "Make the mytoken with the passed name the current one, i.e. the one used if no other mytoken is passed",
+					ArgsUsage:     "<name>",
+					Action:        useFromStore,
+					ShellComplete: shellComplete(completeStoreNames),
+				},
+				{
+					Name: "remove",
+					Aliases: []string{
+						"rm",
+						"delete",
+					},
+					Usage:         "Remove the mytoken with the passed name from the store",
+					ArgsUsage:     "<name>",
+					Action:        removeFromStore,
+					ShellComplete: shellComplete(completeStoreNames),
+				},
+			},
+		},
+	)
+}
+
+func addToStore(_ context.Context, cmd *cli.Command) error {
+	if cmd.Args().Len() < 1 {
+		return fmt.Errorf("name required")
+	}
+	name := cmd.Args().Get(0)
+	s, err := store.Load()
+	if err != nil {
+		return err
+	}
+	s.Add(newStoreEntry(name, storeOptions.MustGetToken()))
+	if storeOptions.Use || len(s.Entries) == 1 {
+		s.Current = name
+	}
+	if err = s.Save(); err != nil {
+		return err
+	}
+	fmt.Printf("Mytoken '%s' added to the store\n", name)
+	return nil
+}
+
+// newStoreEntry creates a store entry for a mytoken of the current mytoken instance; the expiration and the MOM-ID are
+// taken from the token and from introspection if possible
+func newStoreEntry(name, mToken string) *store.Entry {
+	e := &store.Entry{
+		Name:    name,
+		Issuer:  config.Get().URL,
+		Mytoken: mToken,
+	}
+	if jwtutils.IsJWT(mToken) {
+		if exp, ok := jwtutils.GetValueFromJWT(log.StandardLogger(), mToken, "exp").(float64); ok {
+			e.ExpiresAt = int64(exp)
+		}
+	}
+	if res, err := config.Get().Mytoken().Tokeninfo.Introspect(mToken); err == nil {
+		e.MOMID = res.MOMID
+		if e.ExpiresAt == 0 {
+			e.ExpiresAt = res.Token.ExpiresAt
+		}
+	}
+	return e
+}
+
+func listStore(_ context.Context, _ *cli.Command) error {
+	s, err := store.Load()
+	if err != nil {
+		return err
+	}
+	outputData := make([]tablewriter.TableWriter, len(s.Entries))
+	for i, e := range s.Entries {
+		outputData[i] = tableStoreEntry{
+			Entry:   *e,
+			current: e.Name == s.Current,
+		}
+	}
+	tablewriter.PrintTableData(outputData)
+	return nil
+}
+
+type tableStoreEntry struct {
+	store.Entry
+	current bool
+}
+
+func (tableStoreEntry) TableGetHeader() []string {
+	return []string{
+		"Current",
+		"Name",
+		"Instance",
+		"MOM-ID",
+		"Expires",
+	}
+}
+
+func (e tableStoreEntry) TableGetData() any {
+	return struct {
+		Current   bool   `json:"current"`
+		Name      string `json:"name"`
+		Issuer    string `json:"issuer"`
+		MOMID     string `json:"mom_id,omitempty"`
+		ExpiresAt int64  `json:"expires_at,omitempty"`
+	}{
+		Current:   e.current,
+		Name:      e.Name,
+		Issuer:    e.Issuer,
+		MOMID:     e.MOMID,
+		ExpiresAt: e.ExpiresAt,
+	}
+}
+
+func (e tableStoreEntry) TableGetRow() []string {
+	const timeFmt = "2006-01-02 15:04:05"
+	current := ""
+	if e.current {
+		current = "*"
+	}
+	expires := color.Italic("does not expire")
+	if e.ExpiresAt > 0 {
+		expires = time.Unix(e.ExpiresAt, 0).Format(timeFmt)
+	}
+	row := []string{
+		current,
+		e.Name,
+		e.Issuer,
+		e.MOMID,
+		expires,
+	}
+	if e.ExpiresAt > 0 && e.ExpiresAt < time.Now().Unix() {
+		for i, r := range row {
+			row[i] = color.Gray(r)
+		}
+	}
+	return row
+}
+
+func useFromStore(_ context.Context, cmd *cli.Command) error {
+	if cmd.Args().Len() < 1 {
+		return fmt.Errorf("name required")
+	}
+	name := cmd.Args().Get(0)
+	s, err := store.Load()
+	if err != nil {
+		return err
+	}
+	if err = s.Use(name); err != nil {
+		return err
+	}
+	if err = s.Save(); err != nil {
+		return err
+	}
+	fmt.Printf("Now using mytoken '%s'\n", name)
+	return nil
+}
+
+func removeFromStore(_ context.Context, cmd *cli.Command) error {
+	if cmd.Args().Len() < 1 {
+		return fmt.Errorf("name required")
+	}
+	name := cmd.Args().Get(0)
+	s, err := store.Load()
+	if err != nil {
+		return err
+	}
+	if err = s.Remove(name); err != nil {
+		return err
+	}
+	if err = s.Save(); err != nil {
+		return err
+	}
+	fmt.Printf("Mytoken '%s' removed from the store\n", name)
+	return nil
+}
@@ -45,10 +45,11 @@ func initTags(parent *cli.Command) {
 				),
 			},
 			{
-				Name:      "update",
-				Usage:     "Update an existing tag",
-				ArgsUsage: "<name>",
-				Action:    updateTag,
+				Name:          "update",
+				Usage:         "Update an existing tag",
+				ArgsUsage:     "<name>",
+				Action:        updateTag,
+				ShellComplete: shellComplete(completeTags),
 				Flags: append(
 					getMTFlags(),
 					&cli.StringFlag{
@@ -69,10 +70,11 @@ func initTags(parent *cli.Command) {
 					"rm",
 					"remove",
 				},
-				Usage:     "Delete a tag",
-				ArgsUsage: "<name>",
-				Action:    deleteTag,
-				Flags:     getMTFlags(),
+				Usage:         "Delete a tag",
+				ArgsUsage:     "<name>",
+				Action:        deleteTag,
+				ShellComplete: shellComplete(completeTags),
+				Flags:         getMTFlags(),
 			},
 		},
 	}
@@ -0,0 +1,759 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/oidc-mytoken/api/v0"
+	mytokenlib "github.com/oidc-mytoken/lib"
+	"github.com/oidc-mytoken/utils/utils/issuerutils"
+	"github.com/pkg/errors"
+	"github.com/urfave/cli/v3"
+
+	"github.com/oidc-mytoken/client/internal/config"
+	"github.com/oidc-mytoken/client/internal/store"
+	"github.com/oidc-mytoken/client/internal/utils/timeexpr"
+	"github.com/oidc-mytoken/client/internal/utils/tui"
+)
+
+var uiOptions MTOptions
+
+func init() {
+	app.Commands = append(
+		app.Commands, &cli.Command{
+			Name:  "ui",
+			Usage: "Manage mytokens in an interactive terminal UI",
+			Description: "Shows the tree of all mytokens together with the details, the history, " +
+				"and the subtokens of the selected mytoken. The selected mytoken can be revoked, tagged, " +
+				"and subscribed to a calendar; subtokens can be created from mytokens that are held locally, " +
+				"i.e. the used mytoken and mytokens in the mytoken store. Press '?' in the UI for the key bindings.",
+			Action: ui,
+			Flags:  getMTFlags(),
+		},
+	)
+}
+
+const (
+	uiTabDetails = iota
+	uiTabHistory
+	uiTabSubtokens
+	uiNumTabs
+)
+
+var uiTabNames = [uiNumTabs]string{
+	"Details",
+	"History",
+	"Subtokens",
+}
+
+// uiRefreshInterval is the interval in which the terminal size is checked
+const uiRefreshInterval = 250 * time.Millisecond
+
+// uiRow is a line of the mytoken tree
+type uiRow struct {
+	tree  api.MytokenEntryTree
+	depth int
+}
+
+// heldMytoken is a mytoken that is available locally and can therefore be used to create subtokens
+type heldMytoken struct {
+	token string
+	// storeName is the name of the token in the mytoken store; it is empty for the used mytoken
+	storeName string
+}
+
+// uiInput is a line of text the user is asked for
+type uiInput struct {
+	label  string
+	value  string
+	submit func(string)
+}
+
+// uiQuestion is a question answered with a single key; any other key cancels
+type uiQuestion struct {
+	text    string
+	answers map[rune]func()
+	// keepStatus is set if cancelling the question should not replace the status
+	keepStatus bool
+	// explicit is set if the question is only closed by one of the answers or the escape key; other keys are ignored
+	explicit bool
+}
+
+// tokenUI is the state of the terminal UI
+type tokenUI struct {
+	screen *tui.Screen
+	server *mytokenlib.MytokenServer
+
+	mToken   string
+	ownMOMID string
+	// unsaved is set to a rotated mytoken that could not be stored back; it is printed when the UI is closed
+	unsaved string
+	held    map[string]heldMytoken
+
+	tree   []api.MytokenEntryTree
+	rows   []uiRow
+	cursor int
+	offset int
+	tab    int
+	scroll int
+
+	details   map[string][]string
+	histories map[string][]string
+	// pendingHistory is the MOM-ID of a mytoken whose history must be loaded
+	pendingHistory string
+
+	status        string
+	statusIsError bool
+	input         *uiInput
+	question      *uiQuestion
+	overlay       []string
+
+	quit        bool
+	exitMessage string
+}
+
+func ui(ctx context.Context, _ *cli.Command) error {
+	if uiOptions.SSH() != "" {
+		return errors.New("the terminal UI cannot be used with --ssh")
+	}
+	// The token must be obtained first, since it determines the mytoken instance
+	mToken := uiOptions.MustGetToken()
+	u := &tokenUI{
+		server: config.Get().Mytoken(),
+		mToken: mToken,
+	}
+	if introspection, err := u.server.Tokeninfo.Introspect(u.mToken); err == nil {
+		u.ownMOMID = introspection.MOMID
+	}
+	if err := u.load(); err != nil {
+		return err
+	}
+	screen, err := tui.Open()
+	if err != nil {
+		return errors.Wrap(err, "cannot start the terminal UI")
+	}
+	u.screen = screen
+	err = u.run(ctx)
+	screen.Close()
+	if u.unsaved != "" {
+		updateMytoken(u.unsaved)
+	}
+	if u.exitMessage != "" {
+		fmt.Println(u.exitMessage)
+	}
+	return err
+}
+
+func (u *tokenUI) run(ctx context.Context) error {
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	ticker := time.NewTicker(uiRefreshInterval)
+	defer ticker.Stop()
+	width, height := u.screen.Size()
+	redraw := true
+	for !u.quit {
+		if redraw {
+			u.draw()
+			if u.loadPendingHistory() {
+				u.draw()
+			}
+		}
+		redraw = true
+		select {
+		case <-ctx.Done():
+			return nil
+		case k, ok := <-u.screen.Keys():
+			if !ok {
+				return nil
+			}
+			u.handleKey(k)
+		case <-ticker.C:
+			// only redraw if the terminal was resized
+			w, h := u.screen.Size()
+			redraw = w != width || h != height
+			width, height = w, h
+		}
+	}
+	return nil
+}
+
+// load obtains the mytoken tree and the locally held mytokens; the selection is kept if the selected mytoken still
+// exists
+func (u *tokenUI) load() error {
+	res, err := u.server.Tokeninfo.APIListMytokens(u.mToken)
+	if err != nil {
+		return err
+	}
+	u.rotate(res.TokenUpdate)
+	selected := ""
+	if e := u.selected(); e != nil {
+		selected = e.tree.Token.MOMID
+	}
+	u.tree = res.Tokens
+	u.rows = nil
+	u.appendRows(u.tree, 0)
+	u.cursor = 0
+	for i, r := range u.rows {
+		if r.tree.Token.MOMID == selected {
+			u.cursor = i
+		}
+	}
+	u.scroll = 0
+	u.details = map[string][]string{}
+	u.histories = map[string][]string{}
+	u.loadHeldMytokens()
+	return nil
+}
+
+func (u *tokenUI) appendRows(tree []api.MytokenEntryTree, depth int) {
+	for _, e := range tree {
+		u.rows = append(u.rows, uiRow{tree: e, depth: depth})
+		u.appendRows(e.Children, depth+1)
+	}
+}
+
+func (u *tokenUI) loadHeldMytokens() {
+	u.held = map[string]heldMytoken{}
+	if s, err := store.Load(); err == nil {
+		for _, e := range s.Entries {
+			if e.MOMID != "" && issuerutils.CompareIssuerURLs(config.Get().URL, e.Issuer) {
+				u.held[e.MOMID] = heldMytoken{token: e.Mytoken, storeName: e.Name}
+			}
+		}
+	}
+	if u.ownMOMID != "" {
+		u.held[u.ownMOMID] = heldMytoken{token: u.mToken}
+	}
+}
+
+func (u *tokenUI) selected() *uiRow {
+	if u.cursor < 0 || u.cursor >= len(u.rows) {
+		return nil
+	}
+	return &u.rows[u.cursor]
+}
+
+// rotate takes over a rotated mytoken; it is written back to where it was read from if possible
+func (u *tokenUI) rotate(update *api.MytokenResponse) {
+	if update == nil || update.Mytoken == "" {
+		return
+	}
+	u.mToken = update.Mytoken
+	opts := MTOptions{}
+	stored, err := storeUpdatedMytoken(opts.MytokenFile(), opts.MytokenName(), u.mToken)
+	if err != nil || !stored {
+		u.unsaved = u.mToken
+		return
+	}
+	u.unsaved = ""
+}
+
+func (u *tokenUI) setStatus(format string, args ...any) {
+	u.status = fmt.Sprintf(format, args...)
+	u.statusIsError = false
+}
+
+func (u *tokenUI) setError(err error) {
+	u.status = err.Error()
+	u.statusIsError = true
+}
+
+// busy shows a status message while a request is running
+func (u *tokenUI) busy(msg string) {
+	u.setStatus("%s", msg)
+	u.draw()
+}
+
+func (u *tokenUI) handleKey(k tui.Key) {
+	if k.Code == tui.KeyCtrlC {
+		u.quit = true
+		return
+	}
+	switch {
+	case u.input != nil:
+		u.handleInputKey(k)
+		return
+	case u.question != nil:
+		q := u.question
+		answer, ok := q.answers[k.Rune]
+		ok = ok && k.Code == tui.KeyRune
+		if !ok && q.explicit && k.Code != tui.KeyEsc {
+			return
+		}
+		u.question = nil
+		u.overlay = nil
+		if ok {
+			answer()
+			return
+		}
+		if !q.keepStatus {
+			u.setStatus("Cancelled.")
+		}
+		return
+	case u.overlay != nil:
+		u.overlay = nil
+		return
+	}
+	u.status = ""
+	switch k.Code {
+	case tui.KeyUp:
+		u.move(-1)
+	case tui.KeyDown:
+		u.move(1)
+	case tui.KeyHome:
+		u.move(-len(u.rows))
+	case tui.KeyEnd:
+		u.move(len(u.rows))
+	case tui.KeyPgUp:
+		u.scroll = max(0, u.scroll-u.pageSize())
+	case tui.KeyPgDown:
+		u.scroll += u.pageSize()
+	case tui.KeyTab, tui.KeyRight:
+		u.switchTab((u.tab + 1) % uiNumTabs)
+	case tui.KeyBacktab, tui.KeyLeft:
+		u.switchTab((u.tab + uiNumTabs - 1) % uiNumTabs)
+	case tui.KeyF5:
+		u.reload()
+	case tui.KeyEsc:
+		u.quit = true
+	case tui.KeyRune:
+		u.handleRune(k.Rune)
+	}
+}
+
+func (u *tokenUI) handleRune(r rune) {
+	switch r {
+	case 'k':
+		u.move(-1)
+	case 'j':
+		u.move(1)
+	case 'g':
+		u.move(-len(u.rows))
+	case 'G':
+		u.move(len(u.rows))
+	case 'h':
+		u.switchTab((u.tab + uiNumTabs - 1) % uiNumTabs)
+	case 'l':
+		u.switchTab((u.tab + 1) % uiNumTabs)
+	case '1', '2', '3':
+		u.switchTab(int(r - '1'))
+	case 'R':
+		u.reload()
+	case 'r':
+		u.askRevoke()
+	case 't':
+		u.askAddTag()
+	case 'T':
+		u.askRemoveTag()
+	case 'c':
+		u.askSubscribeCalendar()
+	case 'n':
+		u.askCreateSubtoken()
+	case '?':
+		u.overlay = uiHelp
+	case 'q':
+		u.quit = true
+	}
+}
+
+func (u *tokenUI) handleInputKey(k tui.Key) {
+	in := u.input
+	switch k.Code {
+	case tui.KeyEsc:
+		u.input = nil
+		u.setStatus("Cancelled.")
+	case tui.KeyEnter:
+		u.input = nil
+		in.submit(strings.TrimSpace(in.value))
+	case tui.KeyBackspace:
+		if r := []rune(in.value); len(r) > 0 {
+			in.value = string(r[:len(r)-1])
+		}
+	case tui.KeyRune:
+		in.value += string(k.Rune)
+	}
+}
+
+func (u *tokenUI) move(delta int) {
+	u.cursor = min(max(u.cursor+delta, 0), len(u.rows)-1)
+	u.scroll = 0
+}
+
+func (u *tokenUI) switchTab(tab int) {
+	u.tab = tab
+	u.scroll = 0
+}
+
+func (u *tokenUI) reload() {
+	u.busy("Reloading ...")
+	if err := u.load(); err != nil {
+		u.setError(err)
+		return
+	}
+	u.setStatus("Reloaded %s.", numMytokens(len(u.rows)))
+}
+
+func (u *tokenUI) ask(label, value string, submit func(string)) {
+	u.input = &uiInput{
+		label:  label,
+		value:  value,
+		submit: submit,
+	}
+}
+
+func (u *tokenUI) askRevoke() {
+	row := u.selected()
+	if row == nil {
+		return
+	}
+	e := row.tree.Token
+	answers := map[rune]func(){
+		'y': func() { u.revoke(e, false) },
+	}
+	text := fmt.Sprintf("Revoke '%s'? [y]es, [n]o", mytokenDisplayName(e))
+	if n := countSubtokens(row.tree.Children); n > 0 {
+		answers['r'] = func() { u.revoke(e, true) }
+		text = fmt.Sprintf(
+			"Revoke '%s'? [y]es, [r]ecursively together with its %s, [n]o", mytokenDisplayName(e),
+			numSubtokens(n),
+		)
+	}
+	if e.MOMID == u.ownMOMID {
+		text += " (this is the used mytoken, the UI quits afterwards)"
+	}
+	u.question = &uiQuestion{
+		text:    text,
+		answers: answers,
+	}
+}
+
+func (u *tokenUI) revoke(e api.MytokenEntry, recursive bool) {
+	u.busy("Revoking ...")
+	if err := u.server.Revocation.RevokeID(e.MOMID, u.mToken, "", recursive); err != nil {
+		u.setError(errors.Wrap(err, "revocation failed"))
+		return
+	}
+	if e.MOMID == u.ownMOMID {
+		u.unsaved = ""
+		u.exitMessage = "The used mytoken was revoked."
+		u.quit = true
+		return
+	}
+	u.reloadWithStatus(fmt.Sprintf("Revoked '%s'.", mytokenDisplayName(e)))
+}
+
+// reloadWithStatus reloads the mytoken tree after a change; the passed status is kept unless reloading fails
+func (u *tokenUI) reloadWithStatus(status string) {
+	if err := u.load(); err != nil {
+		u.setError(errors.Wrap(err, "could not reload the mytokens"))
+		return
+	}
+	u.setStatus("%s", status)
+}
+
+func (u *tokenUI) askAddTag() {
+	row := u.selected()
+	if row == nil {
+		return
+	}
+	e := row.tree.Token
+	u.ask(
+		fmt.Sprintf("Add tag to '%s': ", mytokenDisplayName(e)), "", func(tag string) {
+			if tag == "" {
+				u.setStatus("Cancelled.")
+				return
+			}
+			if len(row.tree.Children) == 0 {
+				u.addTag(e, tag, false)
+				return
+			}
+			u.question = &uiQuestion{
+				text: "Also tag its subtokens? [y]es, [n]o",
+				answers: map[rune]func(){
+					'y': func() { u.addTag(e, tag, true) },
+					'n': func() { u.addTag(e, tag, false) },
+				},
+			}
+		},
+	)
+}
+
+func (u *tokenUI) addTag(e api.MytokenEntry, tag string, includeChildren bool) {
+	u.busy("Adding tag ...")
+	res, err := u.server.Mytoken.Tags().APIAdd(
+		api.AddTagToMytokenRequest{
+			Tag:             api.Tag(tag),
+			Mytoken:         u.mToken,
+			MOMID:           e.MOMID,
+			IncludeChildren: includeChildren,
+		},
+	)
+	if err != nil {
+		u.setError(errors.Wrap(err, "could not add tag"))
+		return
+	}
+	u.rotate(res.TokenUpdate)
+	u.reloadWithStatus(fmt.Sprintf("Tag '%s' added to '%s'.", tag, mytokenDisplayName(e)))
+}
+
+func (u *tokenUI) askRemoveTag() {
+	row := u.selected()
+	if row == nil {
+		return
+	}
+	e := row.tree.Token
+	if len(e.Tags) == 0 {
+		u.setError(errors.Errorf("'%s' has no tags", mytokenDisplayName(e)))
+		return
+	}
+	tags := make([]string, len(e.Tags))
+	for i, t := range e.Tags {
+		tags[i] = string(t.Tag)
+	}
+	value := ""
+	if len(tags) == 1 {
+		value = tags[0]
+	}
+	u.ask(
+		fmt.Sprintf("Remove tag from '%s' (%s): ", mytokenDisplayName(e), strings.Join(tags, ", ")), value,
+		func(tag string) {
+			if tag == "" {
+				u.setStatus("Cancelled.")
+				return
+			}
+			u.removeTag(e, tag, len(row.tree.Children) > 0)
+		},
+	)
+}
+
+func (u *tokenUI) removeTag(e api.MytokenEntry, tag string, includeChildren bool) {
+	u.busy("Removing tag ...")
+	res, err := u.server.Mytoken.Tags().APIRemove(
+		api.RemoveTagFromMytokenRequest{
+			Tag:             api.Tag(tag),
+			Mytoken:         u.mToken,
+			MOMID:           e.MOMID,
+			IncludeChildren: includeChildren,
+		},
+	)
+	if err != nil {
+		u.setError(errors.Wrap(err, "could not remove tag"))
+		return
+	}
+	u.rotate(res.TokenUpdate)
+	u.reloadWithStatus(fmt.Sprintf("Tag '%s' removed from '%s'.", tag, mytokenDisplayName(e)))
+}
+
+func (u *tokenUI) askSubscribeCalendar() {
+	row := u.selected()
+	if row == nil {
+		return
+	}
+	if u.server.Calendars == nil {
+		u.setError(errors.New("the mytoken instance does not support calendars"))
+		return
+	}
+	e := row.tree.Token
+	u.ask(
+		fmt.Sprintf("Subscribe '%s' to calendar: ", mytokenDisplayName(e)), "", func(calendarID string) {
+			if calendarID == "" {
+				u.setStatus("Cancelled.")
+				return
+			}
+			u.busy("Subscribing ...")
+			res, err := u.server.Calendars.APISubscribe(
+				u.mToken, calendarID, api.AddMytokenToCalendarRequest{
+					MomID: e.MOMID,
+				},
+			)
+			if err != nil {
+				u.setError(errors.Wrap(err, "could not subscribe to calendar"))
+				return
+			}
+			u.rotate(res.TokenUpdate)
+			u.setStatus("Subscribed '%s' to calendar '%s'.", mytokenDisplayName(e), calendarID)
+		},
+	)
+}
+
+func (u *tokenUI) askCreateSubtoken() {
+	row := u.selected()
+	if row == nil {
+		return
+	}
+	parent := row.tree.Token
+	held, ok := u.held[parent.MOMID]
+	if !ok {
+		u.setError(
+			errors.New(
+				"subtokens can only be created from mytokens that are held locally, " +
+					"i.e. the used mytoken and mytokens in the mytoken store",
+			),
+		)
+		return
+	}
+	u.ask(
+		fmt.Sprintf("Name of the new subtoken of '%s': ", mytokenDisplayName(parent)), "", func(name string) {
+			u.ask(
+				"Expires (empty for the expiration of the parent, e.g. '7d', 'end-of-month'): ", "",
+				func(expires string) {
+					exp, err := timeexpr.Parse(expires, time.Now())
+					if err != nil {
+						u.setError(err)
+						return
+					}
+					u.createSubtoken(held, name, exp)
+				},
+			)
+		},
+	)
+}
+
+// subtokenRestrictions returns the restrictions of a parent mytoken with the passed expiration applied to all
+// clauses, so the restrictions are always at least as tight as the parent's
+func subtokenRestrictions(parent string, exp int64) api.Restrictions {
+	if exp == 0 {
+		return nil
+	}
+	claims, _ := mytokenClaims(parent)
+	if len(claims.Restrictions) == 0 {
+		return api.Restrictions{{ExpiresAt: exp}}
+	}
+	restrictions := make(api.Restrictions, len(claims.Restrictions))
+	for i, r := range claims.Restrictions {
+		clause := *r
+		if clause.ExpiresAt == 0 || clause.ExpiresAt > exp {
+			clause.ExpiresAt = exp
+		}
+		restrictions[i] = &clause
+	}
+	return restrictions
+}
+
+func (u *tokenUI) createSubtoken(parent heldMytoken, name string, exp int64) {
+	u.busy("Creating subtoken ...")
+	req := api.GeneralMytokenRequest{
+		GrantType:       api.GrantTypeMytoken,
+		Name:            name,
+		Capabilities:    api.NewCapabilities(config.Get().DefaultTokenCapabilities),
+		Restrictions:    subtokenRestrictions(parent.token, exp),
+		ApplicationName: fmt.Sprintf("mytoken client on %s", config.Get().Hostname),
+	}
+	if prefix := config.Get().TokenNamePrefix; name != "" && prefix != "" {
+		req.Name = fmt.Sprintf("%s:%s", prefix, name)
+	}
+	res, err := u.server.Mytoken.APIFromRequest(
+		api.MytokenFromMytokenRequest{
+			GeneralMytokenRequest:        req,
+			Mytoken:                      parent.token,
+			FailOnRestrictionsNotTighter: true,
+		},
+	)
+	if err == nil && res.Mytoken == "" {
+		err = errors.New("server returned empty mytoken")
+	}
+	if err != nil {
+		u.setError(errors.Wrap(err, "could not create subtoken"))
+		return
+	}
+	var storeErr error
+	if res.TokenUpdate != nil {
+		if parent.storeName == "" {
+			u.rotate(res.TokenUpdate)
+		} else {
+			storeErr = store.UpdateMytoken(parent.storeName, res.TokenUpdate.Mytoken)
+		}
+	}
+	u.reloadWithStatus("Subtoken created.")
+	if storeErr != nil {
+		u.setError(errors.Wrap(storeErr, "could not store the rotated parent mytoken"))
+	}
+	u.showCreatedMytoken(res.Mytoken, name)
+}
+
+// showCreatedMytoken shows a newly created mytoken, which cannot be obtained again, and offers to store it
+func (u *tokenUI) showCreatedMytoken(token, name string) {
+	width, _ := u.screen.Size()
+	u.overlay = append(
+		[]string{
+			"The new mytoken is shown only once; copy it or add it to the mytoken store:",
+			"",
+		}, tui.Wrap(token, width-2)...,
+	)
+	u.question = &uiQuestion{
+		text:       "[s]tore the mytoken, [c]lose",
+		keepStatus: true,
+		explicit:   true,
+		answers: map[rune]func(){
+			'c': func() {},
+			's': func() {
+				u.ask(
+					"Name in the mytoken store: ", name, func(storeName string) {
+						if storeName == "" {
+							u.setStatus("Cancelled.")
+							return
+						}
+						u.busy("Storing mytoken ...")
+						if err := addMytokenToStore(storeName, token); err != nil {
+							u.setError(err)
+							return
+						}
+						u.loadHeldMytokens()
+						u.details = map[string][]string{}
+						u.setStatus("Mytoken added to the store as '%s'.", storeName)
+					},
+				)
+			},
+		},
+	}
+}
+
+func addMytokenToStore(name, token string) error {
+	s, err := store.Load()
+	if err != nil {
+		return err
+	}
+	if s.Get(name) != nil {
+		return errors.Errorf("there is already a mytoken with name '%s' in the store", name)
+	}
+	s.Add(newStoreEntry(name, token))
+	return s.Save()
+}
+
+func mytokenDisplayName(e api.MytokenEntry) string {
+	if e.Name == "" {
+		return "unnamed token"
+	}
+	return e.Name
+}
+
+func numSubtokens(n int) string {
+	if n == 1 {
+		return "1 subtoken"
+	}
+	return fmt.Sprintf("%d subtokens", n)
+}
+
+var uiHelp = []string{
+	"Key bindings",
+	"",
+	"  Up/Down, k/j         Select a mytoken",
+	"  Home/End, g/G        Select the first or last mytoken",
+	"  Tab/Shift-Tab, l/h   Switch between details, history, and subtokens",
+	"  1, 2, 3              Show details, history, or subtokens",
+	"  PgUp/PgDown          Scroll the details, history, or subtokens",
+	"  r                    Revoke the selected mytoken",
+	"  t / T                Add / remove a tag",
+	"  c                    Subscribe the selected mytoken to a calendar",
+	"  n                    Create a subtoken of the selected mytoken",
+	"  R, F5                Reload",
+	"  ?                    Show this help",
+	"  q, Esc               Quit",
+	"",
+	"Press any key to close this help.",
+}
@@ -0,0 +1,310 @@
+package commands
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/oidc-mytoken/api/v0"
+
+	"github.com/oidc-mytoken/client/internal/config"
+	"github.com/oidc-mytoken/client/internal/utils/color"
+	"github.com/oidc-mytoken/client/internal/utils/timeexpr"
+	"github.com/oidc-mytoken/client/internal/utils/tui"
+)
+
+const (
+	// uiChromeHeight is the number of lines that are not used by the tree and the pane: the title, the status line,
+	// and the key line
+	uiChromeHeight = 3
+	// uiPaneHeaderHeight is the number of lines of the pane that are used by the tabs
+	uiPaneHeaderHeight = 2
+)
+
+func (u *tokenUI) draw() {
+	width, height := u.screen.Size()
+	bodyHeight := max(height-uiChromeHeight, 1)
+	lines := make([]string, 0, height)
+	lines = append(lines, tui.Reverse(tui.Pad(u.title(width), width)))
+	if u.overlay != nil {
+		for i := 0; i < bodyHeight; i++ {
+			line := ""
+			if i < len(u.overlay) {
+				line = " " + u.overlay[i]
+			}
+			lines = append(lines, line)
+		}
+	} else {
+		leftWidth := min(max(width*2/5, 24), 60)
+		left := u.treeLines(leftWidth, bodyHeight)
+		right := u.paneLines(max(width-leftWidth-3, 0), bodyHeight)
+		separator := color.Gray("│")
+		for i := 0; i < bodyHeight; i++ {
+			lines = append(lines, tui.Pad(left[i], leftWidth)+" "+separator+" "+right[i])
+		}
+	}
+	lines = append(lines, u.statusLine(), u.keyLine())
+	u.screen.Draw(lines)
+}
+
+func (u *tokenUI) title(width int) string {
+	left := " mytoken ui - " + config.Get().URL
+	right := numMytokens(len(u.rows)) + " "
+	if gap := width - tui.Width(left) - tui.Width(right); gap > 0 {
+		return left + strings.Repeat(" ", gap) + right
+	}
+	return left
+}
+
+func (u *tokenUI) statusLine() string {
+	if u.statusIsError {
+		return " " + color.ColorizeText(u.status, "#FF0000")
+	}
+	return " " + u.status
+}
+
+func (u *tokenUI) keyLine() string {
+	switch {
+	case u.input != nil:
+		return " " + u.input.label + u.input.value + tui.Reverse(" ")
+	case u.question != nil:
+		return " " + u.question.text
+	case u.overlay != nil:
+		return ""
+	}
+	return color.Dim(
+		" r revoke  t/T add/remove tag  c calendar  n new subtoken  R reload  ? help  q quit",
+	)
+}
+
+// pageSize returns the number of lines the pane content scrolls with PgUp and PgDown
+func (u *tokenUI) pageSize() int {
+	_, height := u.screen.Size()
+	return max(height-uiChromeHeight-uiPaneHeaderHeight, 1)
+}
+
+// treeLines renders the visible part of the mytoken tree; it always returns height lines
+func (u *tokenUI) treeLines(width, height int) []string {
+	lines := make([]string, height)
+	if len(u.rows) == 0 {
+		lines[0] = color.Italic("no mytokens")
+		return lines
+	}
+	if u.cursor < u.offset {
+		u.offset = u.cursor
+	}
+	if u.cursor >= u.offset+height {
+		u.offset = u.cursor - height + 1
+	}
+	u.offset = min(u.offset, max(len(u.rows)-height, 0))
+	now := time.Now().Unix()
+	for i := 0; i < height && u.offset+i < len(u.rows); i++ {
+		line := u.treeRow(u.rows[u.offset+i], now)
+		if u.offset+i == u.cursor {
+			line = tui.Reverse(tui.Pad(line, width))
+		}
+		lines[i] = line
+	}
+	return lines
+}
+
+func (u *tokenUI) treeRow(row uiRow, now int64) string {
+	e := row.tree.Token
+	var b strings.Builder
+	if row.depth > 0 {
+		b.WriteString(strings.Repeat("  ", row.depth-1) + "└─ ")
+	}
+	switch {
+	case e.ExpiresAt > 0 && e.ExpiresAt < now:
+		b.WriteString(color.Gray(mytokenDisplayName(e) + " (expired)"))
+	case e.Name == "":
+		b.WriteString(color.Italic("unnamed token"))
+	default:
+		b.WriteString(e.Name)
+	}
+	if e.MOMID == u.ownMOMID {
+		b.WriteString(color.Dim(" (used)"))
+	} else if _, ok := u.held[e.MOMID]; ok {
+		b.WriteString(color.Dim(" (stored)"))
+	}
+	for _, t := range e.Tags {
+		b.WriteString(" " + color.ColorizeText("["+string(t.Tag)+"]", t.Color))
+	}
+	return b.String()
+}
+
+// paneLines renders the tabs and the content of the active tab for the selected mytoken; it always returns height
+// lines
+func (u *tokenUI) paneLines(width, height int) []string {
+	tabs := make([]string, uiNumTabs)
+	for i, name := range uiTabNames {
+		label := fmt.Sprintf(" %d %s ", i+1, name)
+		if i == u.tab {
+			label = tui.Reverse(label)
+		} else {
+			label = color.Dim(label)
+		}
+		tabs[i] = label
+	}
+	lines := []string{
+		strings.Join(tabs, " "),
+		color.Gray(strings.Repeat("─", width)),
+	}
+	var content []string
+	if row := u.selected(); row != nil {
+		switch u.tab {
+		case uiTabDetails:
+			content = u.detailLines(row)
+		case uiTabHistory:
+			content = u.historyLines(row)
+		case uiTabSubtokens:
+			content = subtokenLines(row.tree)
+		}
+	}
+	contentHeight := max(height-len(lines), 0)
+	u.scroll = min(u.scroll, max(len(content)-contentHeight, 0))
+	content = content[u.scroll:]
+	if len(content) > contentHeight && contentHeight > 0 {
+		content = append(content[:contentHeight-1:contentHeight-1], color.Dim("... (PgDown for more)"))
+	}
+	lines = append(lines, content...)
+	for len(lines) < height {
+		lines = append(lines, "")
+	}
+	return lines[:height]
+}
+
+// detailLines describes a mytoken; capabilities, restrictions, and rotation are only known for mytokens that are
+// held locally
+func (u *tokenUI) detailLines(row *uiRow) []string {
+	e := row.tree.Token
+	if lines, ok := u.details[e.MOMID]; ok {
+		return lines
+	}
+	now := time.Now()
+	var buf bytes.Buffer
+	w := tabwriter.NewWriter(&buf, 0, 0, 2, ' ', 0)
+	name := e.Name
+	if name == "" {
+		name = color.Italic("unnamed token")
+	}
+	_, _ = fmt.Fprintf(w, "Name:\t%s\n", name)
+	_, _ = fmt.Fprintf(w, "MOM-ID:\t%s\n", e.MOMID)
+	_, _ = fmt.Fprintf(
+		w, "Created:\t%s (%s)\n", time.Unix(e.CreatedAt, 0).Format(infoTimeFmt), timeexpr.Relative(e.CreatedAt, now),
+	)
+	_, _ = fmt.Fprintf(w, "Expires:\t%s\n", describeExpiry(e.ExpiresAt, now))
+	if len(e.Tags) > 0 {
+		tags := make([]string, len(e.Tags))
+		for i, t := range e.Tags {
+			tags[i] = color.ColorizeText(string(t.Tag), t.Color)
+		}
+		_, _ = fmt.Fprintf(w, "Tags:\t%s\n", strings.Join(tags, ", "))
+	}
+	if e.IP != "" {
+		_, _ = fmt.Fprintf(w, "IP:\t%s\n", e.IP)
+	}
+	if e.UserAgent != "" {
+		_, _ = fmt.Fprintf(w, "User Agent:\t%s\n", e.UserAgent)
+	}
+	_, _ = fmt.Fprintf(w, "Subtokens:\t%d\n", countSubtokens(row.tree.Children))
+	held, isHeld := u.held[e.MOMID]
+	switch {
+	case e.MOMID == u.ownMOMID:
+		_, _ = fmt.Fprintf(w, "Held:\t%s\n", "used mytoken")
+	case isHeld:
+		_, _ = fmt.Fprintf(w, "Held:\tin the mytoken store as '%s'\n", held.storeName)
+	}
+	_ = w.Flush()
+	if mt, ok := mytokenClaims(held.token); isHeld && ok {
+		_, _ = fmt.Fprintln(&buf)
+		_, _ = fmt.Fprintln(&buf, "Capabilities:")
+		printTokenCapabilities(&buf, trustedIssuer(mt.Issuer, config.Get().URL), mt.Capabilities)
+		_, _ = fmt.Fprintln(&buf)
+		_, _ = fmt.Fprintln(&buf, "Restrictions:")
+		printTokenRestrictions(&buf, mt.Restrictions, now)
+		_, _ = fmt.Fprintln(&buf)
+		_, _ = fmt.Fprintf(&buf, "Rotation: %s\n", describeRotation(mt.Rotation))
+	} else {
+		_, _ = fmt.Fprintln(&buf)
+		_, _ = fmt.Fprintln(
+			&buf, color.Dim("Capabilities and restrictions are only known for locally held mytokens."),
+		)
+	}
+	lines := strings.Split(strings.TrimRight(buf.String(), "\n"), "\n")
+	u.details[e.MOMID] = lines
+	return lines
+}
+
+// historyLines returns the event history of a mytoken if it was already loaded; otherwise it is marked to be loaded
+// after the screen was drawn
+func (u *tokenUI) historyLines(row *uiRow) []string {
+	if lines, ok := u.histories[row.tree.Token.MOMID]; ok {
+		return lines
+	}
+	u.pendingHistory = row.tree.Token.MOMID
+	return []string{color.Italic("Loading history ...")}
+}
+
+// loadPendingHistory loads the event history that was requested by the last drawing; it returns true if a history
+// was loaded
+func (u *tokenUI) loadPendingHistory() bool {
+	momID := u.pendingHistory
+	if momID == "" {
+		return false
+	}
+	u.pendingHistory = ""
+	res, err := u.server.Tokeninfo.APIHistory(u.mToken, momID)
+	if err != nil {
+		u.histories[momID] = []string{color.ColorizeText(err.Error(), "#FF0000")}
+		return true
+	}
+	u.rotate(res.TokenUpdate)
+	if len(res.Events) == 0 {
+		u.histories[momID] = []string{color.Italic("no events")}
+		return true
+	}
+	const timeFmt = "2006-01-02 15:04:05"
+	var buf bytes.Buffer
+	w := tabwriter.NewWriter(&buf, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "Time\tEvent\tComment\tIP\tUser Agent")
+	for _, ev := range res.Events {
+		_, _ = fmt.Fprintf(
+			w, "%s\t%s\t%s\t%s\t%s\n", time.Unix(ev.Time, 0).Format(timeFmt), ev.Event, ev.Comment, ev.IP,
+			ev.UserAgent,
+		)
+	}
+	_ = w.Flush()
+	u.histories[momID] = strings.Split(strings.TrimRight(buf.String(), "\n"), "\n")
+	return true
+}
+
+// subtokenLines renders the subtokens of a mytoken as a tree
+func subtokenLines(tree api.MytokenEntryTree) []string {
+	if len(tree.Children) == 0 {
+		return []string{color.Italic("no subtokens")}
+	}
+	lines := []string{mytokenDisplayName(tree.Token)}
+	return appendSubtokenLines(lines, tree.Children, "", time.Now())
+}
+
+func appendSubtokenLines(lines []string, children []api.MytokenEntryTree, indent string, now time.Time) []string {
+	for i, c := range children {
+		branch, childIndent := "├─ ", "│  "
+		if i == len(children)-1 {
+			branch, childIndent = "└─ ", "   "
+		}
+		expiry := color.Italic("does not expire")
+		if c.Token.ExpiresAt > 0 {
+			expiry = "expires " + timeexpr.Relative(c.Token.ExpiresAt, now)
+			if c.Token.ExpiresAt < now.Unix() {
+				expiry = color.Gray("expired " + timeexpr.Relative(c.Token.ExpiresAt, now))
+			}
+		}
+		lines = append(lines, fmt.Sprintf("%s%s%s  %s", indent, branch, mytokenDisplayName(c.Token), expiry))
+		lines = appendSubtokenLines(lines, c.Children, indent+childIndent, now)
+	}
+	return lines
+}
@@ -24,8 +24,13 @@ type Config struct {
 	UseWLCGTokenDiscovery    bool              `yaml:"use_wlcg_token_discovery"`
 	Providers                map[string]string `yaml:"providers"`
 
-	usedConfigDir string
-	Hostname      string
+	CurrentContext string              `yaml:"current_context"`
+	Contexts       map[string]*Context `yaml:"contexts"`
+
+	usedConfigDir  string
+	usedConfigFile string
+	activeContext  string
+	Hostname       string
 }
 
 var defaultConfig = Config{
@@ -61,6 +66,16 @@ func (c *Config) SetMytokenServer(mytoken *mytokenlib.MytokenServer) {
 	c.mytoken = mytoken
 }
 
+// Dir returns the directory of the used config file; if no config file was found, the default config directory is
+// returned
+func (c *Config) Dir() string {
+	if c.usedConfigDir != "" {
+		return c.usedConfigDir
+	}
+	homeDir, _ := os.UserHomeDir()
+	return filepath.Join(homeDir, strings.TrimPrefix(possibleConfigLocations[0], "~"))
+}
+
 func SetURL(url string) {
 	conf.URL = url
 	conf.mytoken = nil
@@ -76,6 +91,9 @@ func load(name string, locations []string) {
 		log.Fatal(err)
 	}
 	conf.usedConfigDir = usedLocation
+	if usedLocation != "" {
+		conf.usedConfigFile = filepath.Join(usedLocation, name)
+	}
 	mytokenlib.SetClient(httpclient.Do().GetClient())
 
 	hostname, _ := os.Hostname()
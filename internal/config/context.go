@@ -0,0 +1,112 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+// Context bundles the settings for working with one mytoken instance; the settings of the used context take
+// precedence over the top-level settings of the config file
+type Context struct {
+	URL                      string       `json:"instance" yaml:"instance"`
+	DefaultProvider          string       `json:"default_provider,omitempty" yaml:"default_provider,omitempty"`
+	DefaultTokenCapabilities []string     `json:"default_token_capabilities,omitempty" yaml:"default_token_capabilities,omitempty"`
+	TokenNamePrefix          string       `json:"token_name_prefix,omitempty" yaml:"token_name_prefix,omitempty"`
+	TokenSource              *TokenSource `json:"default_token_source,omitempty" yaml:"default_token_source,omitempty"`
+}
+
+// TokenSource describes where the mytoken is taken from if no mytoken is passed on the command line; only one of
+// the fields should be set
+type TokenSource struct {
+	File string `json:"file,omitempty" yaml:"file,omitempty"`
+	Env  string `json:"env,omitempty" yaml:"env,omitempty"`
+	Name string `json:"name,omitempty" yaml:"name,omitempty"`
+}
+
+// ActiveContext returns the name of the used context or an empty string if no context is used
+func (c *Config) ActiveContext() string {
+	return c.activeContext
+}
+
+// TokenSource returns the default token source of the used context or nil if there is none
+func (c *Config) TokenSource() *TokenSource {
+	if ctx, ok := c.Contexts[c.activeContext]; ok {
+		return ctx.TokenSource
+	}
+	return nil
+}
+
+// UseContext applies the settings of the context with the passed name
+func UseContext(name string) error {
+	ctx, ok := conf.Contexts[name]
+	if !ok || ctx == nil {
+		return errors.Errorf("context '%s' not found in config", name)
+	}
+	if ctx.URL != "" {
+		SetURL(ctx.URL)
+	}
+	if ctx.DefaultProvider != "" {
+		conf.DefaultProvider = ctx.DefaultProvider
+	}
+	if len(ctx.DefaultTokenCapabilities) > 0 {
+		conf.DefaultTokenCapabilities = ctx.DefaultTokenCapabilities
+	}
+	if ctx.TokenNamePrefix != "" {
+		conf.TokenNamePrefix = strings.ReplaceAll(ctx.TokenNamePrefix, "<hostname>", conf.Hostname)
+	}
+	conf.activeContext = name
+	return nil
+}
+
+// SetCurrentContext stores the passed context name as the current context in the config file
+func SetCurrentContext(name string) error {
+	if _, ok := conf.Contexts[name]; !ok {
+		return errors.Errorf("context '%s' not found in config", name)
+	}
+	file := conf.usedConfigFile
+	if file == "" {
+		file = filepath.Join(conf.Dir(), "config.yaml")
+	}
+	var doc yaml.Node
+	data, err := os.ReadFile(file)
+	if err != nil && !os.IsNotExist(err) {
+		return errors.Wrap(err, "could not read config file")
+	}
+	if err = yaml.Unmarshal(data, &doc); err != nil {
+		return errors.Wrapf(err, "could not parse config file %s", file)
+	}
+	if len(doc.Content) == 0 {
+		doc = yaml.Node{
+			Kind:    yaml.DocumentNode,
+			Content: []*yaml.Node{{Kind: yaml.MappingNode}},
+		}
+	}
+	setMappingValue(doc.Content[0], "current_context", name)
+	out, err := yaml.Marshal(&doc)
+	if err != nil {
+		return err
+	}
+	if err = os.WriteFile(file, out, 0600); err != nil {
+		return errors.Wrap(err, "could not write config file")
+	}
+	conf.CurrentContext = name
+	return nil
+}
+
+func setMappingValue(mapping *yaml.Node, key, value string) {
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value == key {
+			mapping.Content[i+1].SetString(value)
+			return
+		}
+	}
+	k := &yaml.Node{}
+	k.SetString(key)
+	v := &yaml.Node{}
+	v.SetString(value)
+	mapping.Content = append(mapping.Content, k, v)
+}
@@ -0,0 +1,120 @@
+// Package store implements a local store of named mytokens
+package store
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+
+	"github.com/oidc-mytoken/client/internal/config"
+)
+
+const storeFileName = "tokens.yaml"
+
+// Entry is a single named mytoken in the Store
+type Entry struct {
+	Name      string `yaml:"name"`
+	Issuer    string `yaml:"issuer"`
+	MOMID     string `yaml:"mom_id,omitempty"`
+	ExpiresAt int64  `yaml:"expires_at,omitempty"`
+	Mytoken   string `yaml:"mytoken"`
+}
+
+// Store holds the named mytokens
+type Store struct {
+	Current string   `yaml:"current,omitempty"`
+	Entries []*Entry `yaml:"mytokens"`
+
+	file string
+}
+
+// Load loads the store from the config directory; if the store does not exist yet, an empty Store is returned
+func Load() (*Store, error) {
+	s := &Store{
+		file: filepath.Join(config.Get().Dir(), storeFileName),
+	}
+	data, err := os.ReadFile(s.file)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return s, nil
+		}
+		return nil, errors.Wrap(err, "could not read mytoken store")
+	}
+	if err = yaml.Unmarshal(data, s); err != nil {
+		return nil, errors.Wrapf(err, "could not parse mytoken store %s", s.file)
+	}
+	return s, nil
+}
+
+// Save writes the store back to disk
+func (s *Store) Save() error {
+	data, err := yaml.Marshal(s)
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(filepath.Dir(s.file), 0700); err != nil {
+		return err
+	}
+	return os.WriteFile(s.file, data, 0600)
+}
+
+// Get returns the Entry with the passed name or nil if there is no such Entry
+func (s *Store) Get(name string) *Entry {
+	for _, e := range s.Entries {
+		if e.Name == name {
+			return e
+		}
+	}
+	return nil
+}
+
+// Add adds an Entry to the store; an existing Entry with the same name is replaced
+func (s *Store) Add(e *Entry) {
+	for i, ee := range s.Entries {
+		if ee.Name == e.Name {
+			s.Entries[i] = e
+			return
+		}
+	}
+	s.Entries = append(s.Entries, e)
+}
+
+// Remove removes the Entry with the passed name from the store
+func (s *Store) Remove(name string) error {
+	for i, e := range s.Entries {
+		if e.Name == name {
+			s.Entries = append(s.Entries[:i], s.Entries[i+1:]...)
+			if s.Current == name {
+				s.Current = ""
+			}
+			return nil
+		}
+	}
+	return errors.Errorf("no mytoken with name '%s' in store", name)
+}
+
+// Use makes the Entry with the passed name the current one
+func (s *Store) Use(name string) error {
+	if s.Get(name) == nil {
+		return errors.Errorf("no mytoken with name '%s' in store", name)
+	}
+	s.Current = name
+	return nil
+}
+
+// UpdateMytoken replaces the mytoken of the Entry with the passed name, e.g. after the mytoken was rotated, and saves
+// the store
+func UpdateMytoken(name, token string) error {
+	s, err := Load()
+	if err != nil {
+		return err
+	}
+	e := s.Get(name)
+	if e == nil {
+		return errors.Errorf("no mytoken with name '%s' in store", name)
+	}
+	e.Mytoken = token
+	return s.Save()
+}
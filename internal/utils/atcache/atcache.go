@@ -0,0 +1,90 @@
+// Package atcache implements an on-disk cache for access tokens located in XDG_RUNTIME_DIR
+package atcache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/oidc-mytoken/client/internal/utils"
+)
+
+type cacheEntry struct {
+	AccessToken string `json:"access_token"`
+	ExpiresAt   int64  `json:"expires_at"`
+}
+
+// cacheDir returns the directory of the cache or an empty string if XDG_RUNTIME_DIR is not set
+func cacheDir() string {
+	dir := os.Getenv("XDG_RUNTIME_DIR")
+	if dir == "" {
+		return ""
+	}
+	return filepath.Join(dir, "mytoken", "at-cache")
+}
+
+// Key returns the cache key for access tokens obtained with the passed parameters, where mytokenID identifies the
+// used mytoken; the order of scopes and audiences does not matter
+func Key(issuer, mytokenID string, scopes, audiences []string) string {
+	s := append([]string{}, scopes...)
+	sort.Strings(s)
+	a := append([]string{}, audiences...)
+	sort.Strings(a)
+	h := sha256.Sum256(
+		[]byte(strings.Join(
+			[]string{
+				issuer,
+				mytokenID,
+				strings.Join(s, " "),
+				strings.Join(a, " "),
+			}, "\n",
+		)),
+	)
+	return hex.EncodeToString(h[:])
+}
+
+// Get returns the cached access token for the passed key and its expiration time if it is still valid for at least
+// minValid
+func Get(key string, minValid time.Duration) (string, int64, bool) {
+	dir := cacheDir()
+	if dir == "" {
+		return "", 0, false
+	}
+	file := filepath.Join(dir, key)
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return "", 0, false
+	}
+	var e cacheEntry
+	if err = json.Unmarshal(data, &e); err != nil || time.Until(time.Unix(e.ExpiresAt, 0)) <= minValid {
+		_ = os.Remove(file)
+		return "", 0, false
+	}
+	return e.AccessToken, e.ExpiresAt, true
+}
+
+// Store stores the passed access token under the passed key; if XDG_RUNTIME_DIR is not set, nothing is stored
+func Store(key, accessToken string, expiresAt int64) error {
+	dir := cacheDir()
+	if dir == "" {
+		return nil
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+	data, err := json.Marshal(
+		cacheEntry{
+			AccessToken: accessToken,
+			ExpiresAt:   expiresAt,
+		},
+	)
+	if err != nil {
+		return err
+	}
+	return utils.WriteFileAtomic(filepath.Join(dir, key), data, 0600)
+}
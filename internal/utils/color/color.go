@@ -147,3 +147,11 @@ func Gray(text string) string {
 	}
 	return fmt.Sprintf("\x1b[38;2;128;128;128m%s\x1b[0m", text)
 }
+
+// Dim returns ANSI escape sequence for dimmed (faint) text
+func Dim(text string) string {
+	if !ShouldUseColors() {
+		return text
+	}
+	return fmt.Sprintf("\x1b[2m%s\x1b[0m", text)
+}
@@ -0,0 +1,70 @@
+// Package completioncache caches the values used for dynamic shell completion for a short time, so a completion does
+// not have to wait on the network every time
+package completioncache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/oidc-mytoken/client/internal/utils"
+)
+
+// MaxAge is the time for which cached completion values are used
+const MaxAge = 2 * time.Minute
+
+func cacheDir() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "mytoken", "completion")
+}
+
+// Key returns the cache key for the passed parts
+func Key(parts ...string) string {
+	h := sha256.Sum256([]byte(strings.Join(parts, "\n")))
+	return hex.EncodeToString(h[:])
+}
+
+// Get returns the cached values for the passed key if they are younger than MaxAge
+func Get(key string) ([]string, bool) {
+	dir := cacheDir()
+	if dir == "" {
+		return nil, false
+	}
+	file := filepath.Join(dir, key)
+	info, err := os.Stat(file)
+	if err != nil || time.Since(info.ModTime()) > MaxAge {
+		return nil, false
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, false
+	}
+	var values []string
+	if err = json.Unmarshal(data, &values); err != nil {
+		return nil, false
+	}
+	return values, true
+}
+
+// Store caches the passed values under the passed key
+func Store(key string, values []string) error {
+	dir := cacheDir()
+	if dir == "" {
+		return nil
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+	data, err := json.Marshal(values)
+	if err != nil {
+		return err
+	}
+	return utils.WriteFileAtomic(filepath.Join(dir, key), data, 0600)
+}
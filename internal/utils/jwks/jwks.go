@@ -0,0 +1,332 @@
+// Package jwks fetches and caches the signing keys of mytoken instances and verifies mytoken signatures with them
+package jwks
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"io"
+	"math/big"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/oidc-mytoken/utils/httpclient"
+	"github.com/pkg/errors"
+)
+
+// Key is a json web key; only the members needed for signature verification are included
+type Key struct {
+	KeyType string `json:"kty"`
+	KeyID   string `json:"kid,omitempty"`
+	Use     string `json:"use,omitempty"`
+	Alg     string `json:"alg,omitempty"`
+	N       string `json:"n,omitempty"`
+	E       string `json:"e,omitempty"`
+	Curve   string `json:"crv,omitempty"`
+	X       string `json:"x,omitempty"`
+	Y       string `json:"y,omitempty"`
+}
+
+// KeySet is a json web key set
+type KeySet struct {
+	Keys []Key `json:"keys"`
+}
+
+// Result describes a successful verification
+type Result struct {
+	KeyID     string
+	Algorithm string
+	// FromCache is true if the key was taken from the local cache, i.e. the key set was not downloaded
+	FromCache bool
+}
+
+type header struct {
+	Alg   string `json:"alg"`
+	KeyID string `json:"kid"`
+}
+
+// Verify verifies the signature of the passed JWT with the keys of the passed issuer. The keys are taken from the
+// local cache; if the cache does not contain a matching key, the key set is downloaded from the uri returned by
+// jwksURI and cached, so later verifications also work offline.
+func Verify(token, issuer string, jwksURI func() (string, error)) (*Result, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("the token is not a signed JWT")
+	}
+	h, err := parseHeader(parts[0])
+	if err != nil {
+		return nil, err
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid signature encoding")
+	}
+	signed := []byte(parts[0] + "." + parts[1])
+
+	if cached, err := loadCache(issuer); err == nil {
+		if key := cached.find(h); key != nil {
+			if err = verifyWithKey(key, h.Alg, signed, sig); err != nil {
+				return nil, err
+			}
+			return &Result{
+				KeyID:     key.KeyID,
+				Algorithm: h.Alg,
+				FromCache: true,
+			}, nil
+		}
+	}
+
+	uri, err := jwksURI()
+	if err != nil {
+		return nil, errors.Wrap(err, "no cached key found and the key set cannot be obtained")
+	}
+	set, err := fetch(uri)
+	if err != nil {
+		return nil, errors.Wrap(err, "no cached key found and the key set cannot be obtained")
+	}
+	if err = storeCache(issuer, set); err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "could not cache key set: %s\n", err)
+	}
+	key := set.find(h)
+	if key == nil {
+		return nil, errors.Errorf("no key with id '%s' in the key set of %s", h.KeyID, issuer)
+	}
+	if err = verifyWithKey(key, h.Alg, signed, sig); err != nil {
+		return nil, err
+	}
+	return &Result{
+		KeyID:     key.KeyID,
+		Algorithm: h.Alg,
+	}, nil
+}
+
+func parseHeader(s string) (header, error) {
+	var h header
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return h, errors.Wrap(err, "invalid JWT header")
+	}
+	if err = json.Unmarshal(data, &h); err != nil {
+		return h, errors.Wrap(err, "invalid JWT header")
+	}
+	if h.Alg == "" || strings.EqualFold(h.Alg, "none") {
+		return h, errors.New("the token is not signed")
+	}
+	return h, nil
+}
+
+// find returns the key matching the key id of the header; if the header has no key id, the only signing key is used
+func (s *KeySet) find(h header) *Key {
+	var candidates []*Key
+	for i := range s.Keys {
+		k := &s.Keys[i]
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		if h.KeyID != "" && k.KeyID == h.KeyID {
+			return k
+		}
+		if h.KeyID == "" && (k.Alg == "" || k.Alg == h.Alg) {
+			candidates = append(candidates, k)
+		}
+	}
+	if len(candidates) == 1 {
+		return candidates[0]
+	}
+	return nil
+}
+
+func fetch(uri string) (*KeySet, error) {
+	resp, err := httpclient.Do().GetClient().Get(uri)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.Errorf("could not download key set from %s: %s", uri, resp.Status)
+	}
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	var set KeySet
+	if err = json.Unmarshal(data, &set); err != nil {
+		return nil, errors.Wrapf(err, "invalid key set from %s", uri)
+	}
+	return &set, nil
+}
+
+func cacheFile(issuer string) (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+	sum := sha256.Sum256([]byte(strings.TrimSuffix(issuer, "/")))
+	return filepath.Join(dir, "mytoken", "jwks", hex.EncodeToString(sum[:8])+".json"), nil
+}
+
+func loadCache(issuer string) (*KeySet, error) {
+	file, err := cacheFile(issuer)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	var set KeySet
+	if err = json.Unmarshal(data, &set); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return &set, nil
+}
+
+func storeCache(issuer string, set *KeySet) error {
+	file, err := cacheFile(issuer)
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(filepath.Dir(file), 0700); err != nil {
+		return errors.WithStack(err)
+	}
+	data, err := json.Marshal(set)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	return errors.WithStack(os.WriteFile(file, data, 0600))
+}
+
+func verifyWithKey(key *Key, alg string, signed, sig []byte) error {
+	if key.Alg != "" && key.Alg != alg {
+		return errors.Errorf("the token is signed with %s, but the key is for %s", alg, key.Alg)
+	}
+	pub, err := key.publicKey()
+	if err != nil {
+		return err
+	}
+	var ok bool
+	switch alg {
+	case "RS256", "RS384", "RS512", "PS256", "PS384", "PS512":
+		rsaKey, isRSA := pub.(*rsa.PublicKey)
+		if !isRSA {
+			return errors.Errorf("key type %s cannot be used for %s", key.KeyType, alg)
+		}
+		h, hf := hashFor(alg)
+		h.Write(signed)
+		if alg[0] == 'R' {
+			ok = rsa.VerifyPKCS1v15(rsaKey, hf, h.Sum(nil), sig) == nil
+		} else {
+			ok = rsa.VerifyPSS(rsaKey, hf, h.Sum(nil), sig, nil) == nil
+		}
+	case "ES256", "ES384", "ES512":
+		ecKey, isEC := pub.(*ecdsa.PublicKey)
+		if !isEC {
+			return errors.Errorf("key type %s cannot be used for %s", key.KeyType, alg)
+		}
+		size := (ecKey.Curve.Params().BitSize + 7) / 8
+		if len(sig) != 2*size {
+			return errors.New("invalid signature length")
+		}
+		h, _ := hashFor(alg)
+		h.Write(signed)
+		r := new(big.Int).SetBytes(sig[:size])
+		s := new(big.Int).SetBytes(sig[size:])
+		ok = ecdsa.Verify(ecKey, h.Sum(nil), r, s)
+	case "EdDSA":
+		edKey, isEd := pub.(ed25519.PublicKey)
+		if !isEd {
+			return errors.Errorf("key type %s cannot be used for %s", key.KeyType, alg)
+		}
+		ok = ed25519.Verify(edKey, signed, sig)
+	default:
+		return errors.Errorf("unsupported signing algorithm %s", alg)
+	}
+	if !ok {
+		return errors.New("invalid signature")
+	}
+	return nil
+}
+
+func hashFor(alg string) (hash.Hash, crypto.Hash) {
+	switch alg[2:] {
+	case "384":
+		return sha512.New384(), crypto.SHA384
+	case "512":
+		return sha512.New(), crypto.SHA512
+	default:
+		return sha256.New(), crypto.SHA256
+	}
+}
+
+func (k *Key) publicKey() (crypto.PublicKey, error) {
+	switch k.KeyType {
+	case "RSA":
+		n, err := decodeBigInt(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeBigInt(k.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{
+			N: n,
+			E: int(e.Int64()),
+		}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Curve {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, errors.Errorf("unsupported curve %s", k.Curve)
+		}
+		x, err := decodeBigInt(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeBigInt(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{
+			Curve: curve,
+			X:     x,
+			Y:     y,
+		}, nil
+	case "OKP":
+		if k.Curve != "Ed25519" {
+			return nil, errors.Errorf("unsupported curve %s", k.Curve)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil || len(x) != ed25519.PublicKeySize {
+			return nil, errors.New("invalid Ed25519 key")
+		}
+		return ed25519.PublicKey(x), nil
+	default:
+		return nil, errors.Errorf("unsupported key type %s", k.KeyType)
+	}
+}
+
+func decodeBigInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid key")
+	}
+	return new(big.Int).SetBytes(b), nil
+}
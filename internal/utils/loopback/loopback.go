@@ -0,0 +1,148 @@
+// Package loopback receives the redirect at the end of the authorization flow on the loopback interface; the redirect
+// only signals that the flow was completed
+package loopback
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"html"
+	"net"
+	"net/http"
+	"os/exec"
+	"runtime"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const callbackPath = "/callback"
+
+const responsePage = `<!DOCTYPE html>
+<html><head><meta charset="utf-8"><title>mytoken</title></head>
+<body style="font-family: sans-serif; text-align: center; margin-top: 4em;">
+<h2>%s</h2><p>%s</p>
+</body></html>`
+
+func randomString(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", errors.WithStack(err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// Result is the outcome of the redirect to the loopback listener
+type Result struct {
+	Error            string
+	ErrorDescription string
+}
+
+// Err returns the error passed in the redirect, or nil if the redirect indicated success
+func (r Result) Err() error {
+	if r.Error == "" {
+		return nil
+	}
+	if r.ErrorDescription != "" {
+		return fmt.Errorf("%s: %s", r.Error, r.ErrorDescription)
+	}
+	return errors.New(r.Error)
+}
+
+// Listener is a http server on the loopback interface that receives the redirect at the end of the authorization
+// code flow
+type Listener struct {
+	listener net.Listener
+	server   *http.Server
+	state    string
+	results  chan Result
+}
+
+// Listen starts a Listener on a random port of 127.0.0.1
+func Listen() (*Listener, error) {
+	state, err := randomString(16)
+	if err != nil {
+		return nil, err
+	}
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, errors.Wrap(err, "could not start loopback listener")
+	}
+	l := &Listener{
+		listener: ln,
+		state:    state,
+		results:  make(chan Result, 1),
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc(callbackPath, l.handleCallback)
+	l.server = &http.Server{
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	go func() {
+		_ = l.server.Serve(ln)
+	}()
+	return l, nil
+}
+
+// RedirectURI returns the uri the browser should be redirected to; it includes a random state, so that only the
+// redirect for this flow is accepted
+func (l *Listener) RedirectURI() string {
+	return fmt.Sprintf("http://%s%s?state=%s", l.listener.Addr().String(), callbackPath, l.state)
+}
+
+// Results returns the channel on which the result of the redirect is delivered
+func (l *Listener) Results() <-chan Result {
+	return l.results
+}
+
+// Close stops the Listener
+func (l *Listener) Close() error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	return l.server.Shutdown(ctx)
+}
+
+func (l *Listener) handleCallback(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	if q.Get("state") != l.state {
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = fmt.Fprintf(w, responsePage, "Invalid request", "The state does not match; please try again.")
+		return
+	}
+	res := Result{
+		Error:            q.Get("error"),
+		ErrorDescription: q.Get("error_description"),
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := res.Err(); err != nil {
+		_, _ = fmt.Fprintf(w, responsePage, "Authorization failed", html.EscapeString(err.Error()))
+	} else {
+		_, _ = fmt.Fprintf(w, responsePage, "Authorization completed", "You can close this window now.")
+	}
+	select {
+	case l.results <- res:
+	default:
+	}
+}
+
+// OpenBrowser opens the passed url in the user's default browser
+func OpenBrowser(url string) error {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "windows":
+		cmd = exec.Command("rundll32", "url.dll,FileProtocolHandler", url)
+	case "darwin":
+		cmd = exec.Command("open", url)
+	default:
+		cmd = exec.Command("xdg-open", url)
+	}
+	if err := cmd.Start(); err != nil {
+		return errors.WithStack(err)
+	}
+	go func() {
+		_ = cmd.Wait()
+	}()
+	return nil
+}
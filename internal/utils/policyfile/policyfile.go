@@ -0,0 +1,134 @@
+// Package policyfile reads restriction, rotation, and profile files written in json or yaml and validates them
+package policyfile
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+// Read reads a json or yaml file and returns its content as generic json data, i.e. objects are map[string]any,
+// arrays are []any, and numbers are json.Number; yaml is used if the file has a .yaml or .yml extension or does not
+// contain valid json
+func Read(path string) (any, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		return parseJSON(content)
+	case ".yaml", ".yml":
+		return parseYAML(content)
+	}
+	if json.Valid(content) {
+		return parseJSON(content)
+	}
+	return parseYAML(content)
+}
+
+func parseJSON(content []byte) (any, error) {
+	dec := json.NewDecoder(bytes.NewReader(content))
+	dec.UseNumber()
+	var data any
+	if err := dec.Decode(&data); err != nil {
+		return nil, errors.Wrap(err, "invalid json")
+	}
+	return data, nil
+}
+
+func parseYAML(content []byte) (any, error) {
+	var data any
+	if err := yaml.Unmarshal(content, &data); err != nil {
+		return nil, errors.Wrap(err, "invalid yaml")
+	}
+	data, err := normalizeYAML(data, "")
+	if err != nil {
+		return nil, err
+	}
+	// round trip through json so the data has the same types as data read from a json file
+	j, err := json.Marshal(data)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return parseJSON(j)
+}
+
+// normalizeYAML converts maps with non-string keys, which yaml allows but json does not, into an error that
+// includes the path
+func normalizeYAML(data any, path string) (any, error) {
+	switch v := data.(type) {
+	case map[string]any:
+		for k, vv := range v {
+			n, err := normalizeYAML(vv, joinKey(path, k))
+			if err != nil {
+				return nil, err
+			}
+			v[k] = n
+		}
+		return v, nil
+	case map[any]any:
+		m := make(map[string]any, len(v))
+		for k, vv := range v {
+			ks, ok := k.(string)
+			if !ok {
+				return nil, errors.Errorf("%s: keys must be strings, got '%v'", rootName(path), k)
+			}
+			n, err := normalizeYAML(vv, joinKey(path, ks))
+			if err != nil {
+				return nil, err
+			}
+			m[ks] = n
+		}
+		return m, nil
+	case []any:
+		for i, vv := range v {
+			n, err := normalizeYAML(vv, fmt.Sprintf("%s[%d]", path, i))
+			if err != nil {
+				return nil, err
+			}
+			v[i] = n
+		}
+		return v, nil
+	default:
+		return v, nil
+	}
+}
+
+// Decode validates the generic json data against the type of v and then decodes it into v; name is used as the
+// root of the field paths in error messages, e.g. 'restrictions[1].usages_AT: expected integer'. The unknown fields,
+// which are ignored, are returned.
+func Decode(data any, name string, v any) ([]ValidationError, error) {
+	unknown, err := Validate(data, v, name)
+	if err != nil {
+		return nil, err
+	}
+	j, err := json.Marshal(data)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if err = json.Unmarshal(j, v); err != nil {
+		return nil, errors.Wrap(err, name)
+	}
+	return unknown, nil
+}
+
+func joinKey(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+func rootName(path string) string {
+	if path == "" {
+		return "(root)"
+	}
+	return path
+}
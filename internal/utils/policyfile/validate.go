@@ -0,0 +1,249 @@
+package policyfile
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+var jsonUnmarshalerType = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+
+// ValidationError is an error for a value that does not match the expected structure
+type ValidationError struct {
+	Path    string
+	Message string
+}
+
+// Error implements the error interface
+func (e ValidationError) Error() string {
+	return fmt.Sprintf("%s: %s", rootName(e.Path), e.Message)
+}
+
+// Validate checks that the generic json data matches the structure of the type of v; the json field names are
+// matched like encoding/json does, i.e. case-insensitively if there is no exact match. Unknown fields are not an
+// error, since encoding/json ignores them, but they are returned, so they can be reported. path is used as the root
+// of the field paths in errors.
+func Validate(data any, v any, path string) ([]ValidationError, error) {
+	t := reflect.TypeOf(v)
+	for t != nil && t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t == nil {
+		return nil, nil
+	}
+	var vd validator
+	err := vd.validate(data, t, path)
+	return vd.unknown, err
+}
+
+// validator collects the unknown fields found during a validation
+type validator struct {
+	unknown []ValidationError
+}
+
+func (vd *validator) validate(data any, t reflect.Type, path string) error {
+	if data == nil {
+		// null is valid for all types and results in the zero value
+		return nil
+	}
+	if t.Kind() != reflect.Pointer && t.Kind() != reflect.Interface &&
+		reflect.PointerTo(t).Implements(jsonUnmarshalerType) {
+		return validateWithUnmarshaler(data, t, path)
+	}
+	switch t.Kind() {
+	case reflect.Pointer:
+		return vd.validate(data, t.Elem(), path)
+	case reflect.Interface:
+		return nil
+	case reflect.Struct:
+		return vd.validateStruct(data, t, path)
+	case reflect.Slice, reflect.Array:
+		arr, ok := data.([]any)
+		if !ok {
+			return expected("array", data, path)
+		}
+		for i, d := range arr {
+			if err := vd.validate(d, t.Elem(), fmt.Sprintf("%s[%d]", path, i)); err != nil {
+				return err
+			}
+		}
+		return nil
+	case reflect.Map:
+		obj, ok := data.(map[string]any)
+		if !ok {
+			return expected("object", data, path)
+		}
+		for _, k := range sortedKeys(obj) {
+			if err := vd.validate(obj[k], t.Elem(), joinKey(path, k)); err != nil {
+				return err
+			}
+		}
+		return nil
+	case reflect.String:
+		if _, ok := data.(string); !ok {
+			return expected("string", data, path)
+		}
+		return nil
+	case reflect.Bool:
+		if _, ok := data.(bool); !ok {
+			return expected("boolean", data, path)
+		}
+		return nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, ok := data.(json.Number)
+		if !ok {
+			return expected("integer", data, path)
+		}
+		if _, err := n.Int64(); err != nil {
+			return expected("integer", data, path)
+		}
+		return nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, ok := data.(json.Number)
+		if !ok {
+			return expected("non-negative integer", data, path)
+		}
+		if i, err := n.Int64(); err != nil || i < 0 {
+			return expected("non-negative integer", data, path)
+		}
+		return nil
+	case reflect.Float32, reflect.Float64:
+		if _, ok := data.(json.Number); !ok {
+			return expected("number", data, path)
+		}
+		return nil
+	default:
+		return nil
+	}
+}
+
+func (vd *validator) validateStruct(data any, t reflect.Type, path string) error {
+	obj, ok := data.(map[string]any)
+	if !ok {
+		return expected("object", data, path)
+	}
+	fields := map[string]reflect.StructField{}
+	collectFields(t, fields)
+	for _, k := range sortedKeys(obj) {
+		f, ok := lookupField(fields, k)
+		if !ok {
+			vd.unknown = append(
+				vd.unknown, ValidationError{
+					Path:    joinKey(path, k),
+					Message: "unknown field",
+				},
+			)
+			continue
+		}
+		if err := vd.validate(obj[k], f.Type, joinKey(path, k)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// lookupField returns the field for a json key; like encoding/json an exact match is preferred, otherwise the key
+// is matched case-insensitively
+func lookupField(fields map[string]reflect.StructField, key string) (reflect.StructField, bool) {
+	if f, ok := fields[key]; ok {
+		return f, true
+	}
+	for _, name := range sortedFieldNames(fields) {
+		if strings.EqualFold(name, key) {
+			return fields[name], true
+		}
+	}
+	return reflect.StructField{}, false
+}
+
+func sortedFieldNames(fields map[string]reflect.StructField) []string {
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// collectFields collects the fields of a struct by their json name; fields of embedded structs without a json name
+// are promoted as encoding/json does
+func collectFields(t reflect.Type, fields map[string]reflect.StructField) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, _, _ := strings.Cut(tag, ",")
+		if f.Anonymous && name == "" {
+			ft := f.Type
+			if ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				collectFields(ft, fields)
+				continue
+			}
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		if _, exists := fields[name]; !exists {
+			fields[name] = f
+		}
+	}
+}
+
+func sortedKeys(obj map[string]any) []string {
+	keys := make([]string, 0, len(obj))
+	for k := range obj {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func validateWithUnmarshaler(data any, t reflect.Type, path string) error {
+	j, err := json.Marshal(data)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if err = json.Unmarshal(j, reflect.New(t).Interface()); err != nil {
+		return ValidationError{
+			Path:    path,
+			Message: fmt.Sprintf("invalid value %s", j),
+		}
+	}
+	return nil
+}
+
+func expected(what string, data any, path string) error {
+	return ValidationError{
+		Path:    path,
+		Message: fmt.Sprintf("expected %s, got %s", what, jsonTypeName(data)),
+	}
+}
+
+func jsonTypeName(data any) string {
+	switch v := data.(type) {
+	case map[string]any:
+		return "object"
+	case []any:
+		return "array"
+	case string:
+		return fmt.Sprintf("string %q", v)
+	case bool:
+		return fmt.Sprintf("boolean %t", v)
+	case json.Number:
+		return fmt.Sprintf("number %s", v)
+	default:
+		return fmt.Sprintf("%T", v)
+	}
+}
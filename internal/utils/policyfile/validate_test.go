@@ -0,0 +1,203 @@
+package policyfile
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type testEmbedded struct {
+	Comment string `json:"comment"`
+}
+
+type testRestriction struct {
+	testEmbedded
+	Scope     string            `json:"scope,omitempty"`
+	UsagesAT  *uint             `json:"usages_AT,omitempty"`
+	ExpiresAt int64             `json:"exp"`
+	Hosts     []string          `json:"hosts"`
+	Labels    map[string]string `json:"labels"`
+	Enabled   bool              `json:"enabled"`
+	Ratio     float64           `json:"ratio"`
+	Since     time.Time         `json:"since"`
+	Ignored   string            `json:"-"`
+	NoTag     string
+}
+
+type testPolicy struct {
+	Restrictions []testRestriction `json:"restrictions"`
+}
+
+func mustParseJSON(t *testing.T, s string) any {
+	t.Helper()
+	data, err := parseJSON([]byte(s))
+	if err != nil {
+		t.Fatalf("invalid test data: %s", err)
+	}
+	return data
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		err     string
+		unknown []string
+	}{
+		{
+			name: "valid",
+			data: `{"restrictions": [{"scope": "openid", "usages_AT": 10, "exp": 1700000000, "hosts": ["a", "b"],
+				"labels": {"k": "v"}, "enabled": true, "ratio": 0.5, "since": "2026-03-01T12:00:00Z",
+				"comment": "embedded", "NoTag": "x"}]}`,
+		},
+		{name: "null values", data: `{"restrictions": [{"scope": null, "usages_AT": null, "hosts": null}]}`},
+		{name: "empty", data: `{}`},
+		{name: "case-insensitive keys", data: `{"Restrictions": [{"USAGES_at": 1, "Scope": "openid", "notag": "x"}]}`},
+		{
+			name: "unknown fields",
+			data: `{"restrictions": [{"scope": "openid", "scopes": "x", "-": 1, "Ignored": "y"}], "extra": true}`,
+			unknown: []string{
+				"policy.extra", "policy.restrictions[0].-", "policy.restrictions[0].Ignored",
+				"policy.restrictions[0].scopes",
+			},
+		},
+		{name: "root type", data: `[]`, err: "policy: expected object, got array"},
+		{
+			name: "integer type",
+			data: `{"restrictions": [{"exp": "tomorrow"}]}`,
+			err:  `policy.restrictions[0].exp: expected integer, got string "tomorrow"`,
+		},
+		{
+			name: "fraction for integer",
+			data: `{"restrictions": [{"exp": 1.5}]}`,
+			err:  "policy.restrictions[0].exp: expected integer, got number 1.5",
+		},
+		{
+			name: "negative unsigned",
+			data: `{"restrictions": [{}, {"usages_AT": -1}]}`,
+			err:  "policy.restrictions[1].usages_AT: expected non-negative integer, got number -1",
+		},
+		{
+			name: "array type",
+			data: `{"restrictions": [{"hosts": "a"}]}`,
+			err:  `policy.restrictions[0].hosts: expected array, got string "a"`,
+		},
+		{
+			name: "array element type",
+			data: `{"restrictions": [{"hosts": ["a", 1]}]}`,
+			err:  "policy.restrictions[0].hosts[1]: expected string, got number 1",
+		},
+		{
+			name: "map value type",
+			data: `{"restrictions": [{"labels": {"k": false}}]}`,
+			err:  "policy.restrictions[0].labels.k: expected string, got boolean false",
+		},
+		{
+			name: "boolean type",
+			data: `{"restrictions": [{"enabled": "yes"}]}`,
+			err:  `policy.restrictions[0].enabled: expected boolean, got string "yes"`,
+		},
+		{
+			name: "number type",
+			data: `{"restrictions": [{"ratio": [1]}]}`,
+			err:  "policy.restrictions[0].ratio: expected number, got array",
+		},
+		{
+			name: "embedded field type",
+			data: `{"restrictions": [{"comment": {}}]}`,
+			err:  "policy.restrictions[0].comment: expected string, got object",
+		},
+		{
+			name: "unmarshaler",
+			data: `{"restrictions": [{"since": "yesterday"}]}`,
+			err:  `policy.restrictions[0].since: invalid value "yesterday"`,
+		},
+	}
+	for _, test := range tests {
+		t.Run(
+			test.name, func(t *testing.T) {
+				unknown, err := Validate(mustParseJSON(t, test.data), &testPolicy{}, "policy")
+				if test.err != "" {
+					var verr ValidationError
+					if !errors.As(err, &verr) {
+						t.Fatalf("expected a validation error, got %v", err)
+					}
+					if err.Error() != test.err {
+						t.Errorf("expected error '%s', got '%s'", test.err, err)
+					}
+					return
+				}
+				if err != nil {
+					t.Fatalf("unexpected error: %s", err)
+				}
+				var paths []string
+				for _, u := range unknown {
+					paths = append(paths, u.Path)
+				}
+				if !reflect.DeepEqual(paths, test.unknown) {
+					t.Errorf("expected unknown fields %v, got %v", test.unknown, paths)
+				}
+			},
+		)
+	}
+}
+
+func TestLookupFieldPrefersExactMatch(t *testing.T) {
+	type s struct {
+		Lower string `json:"name"`
+		Upper int    `json:"Name"`
+	}
+	fields := map[string]reflect.StructField{}
+	collectFields(reflect.TypeOf(s{}), fields)
+	tests := []struct {
+		key   string
+		field string
+	}{
+		{key: "name", field: "Lower"},
+		{key: "Name", field: "Upper"},
+		{key: "NAME", field: "Upper"},
+	}
+	for _, test := range tests {
+		f, ok := lookupField(fields, test.key)
+		if !ok {
+			t.Fatalf("'%s': no field found", test.key)
+		}
+		if f.Name != test.field {
+			t.Errorf("'%s': expected field %s, got %s", test.key, test.field, f.Name)
+		}
+	}
+}
+
+func TestDecodeYAML(t *testing.T) {
+	data, err := parseYAML(
+		[]byte(`restrictions:
+  - scope: openid
+    usages_AT: 3
+    hosts: [a]
+    unknown: 1
+`),
+	)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	var p testPolicy
+	unknown, err := Decode(data, "policy", &p)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(unknown) != 1 || unknown[0].Error() != "policy.restrictions[0].unknown: unknown field" {
+		t.Errorf("unexpected unknown fields %v", unknown)
+	}
+	if len(p.Restrictions) != 1 || p.Restrictions[0].Scope != "openid" || p.Restrictions[0].UsagesAT == nil ||
+		*p.Restrictions[0].UsagesAT != 3 {
+		t.Errorf("unexpected result %+v", p)
+	}
+}
+
+func TestParseYAMLNonStringKey(t *testing.T) {
+	_, err := parseYAML([]byte("restrictions:\n  - 1: a\n"))
+	if err == nil || err.Error() != "restrictions[0]: keys must be strings, got '1'" {
+		t.Errorf("unexpected error %v", err)
+	}
+}
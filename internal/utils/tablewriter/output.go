@@ -0,0 +1,171 @@
+package tablewriter
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+// The supported output formats
+const (
+	FormatTable = "table"
+	FormatJSON  = "json"
+	FormatYAML  = "yaml"
+	FormatCSV   = "csv"
+	// FormatJSONLines prints one compact json object per line
+	FormatJSONLines = "jsonl"
+)
+
+// Formats lists the supported output formats
+var Formats = []string{
+	FormatTable,
+	FormatJSON,
+	FormatYAML,
+	FormatCSV,
+	FormatJSONLines,
+}
+
+var format = FormatTable
+
+// SetFormat sets the output format used by PrintTableData and PrintData
+func SetFormat(f string) error {
+	for _, ff := range Formats {
+		if f == ff {
+			format = f
+			return nil
+		}
+	}
+	return errors.Errorf("unsupported output format '%s'", f)
+}
+
+// MachineReadable checks if a machine-readable output format is used
+func MachineReadable() bool {
+	return format != FormatTable
+}
+
+// Structured checks if a structured output format, i.e. json, json lines, or yaml, is used
+func Structured() bool {
+	return format == FormatJSON || format == FormatYAML || format == FormatJSONLines
+}
+
+// Format returns the used output format
+func Format() string {
+	return format
+}
+
+// DataGetter can be implemented by a TableWriter to provide the data that is used for json and yaml output; if it
+// is not implemented the TableWriter itself is marshalled
+type DataGetter interface {
+	TableGetData() any
+}
+
+func fPrintTableDataFormatted(out io.Writer, data []TableWriter) error {
+	switch format {
+	case FormatCSV:
+		return fPrintCSV(out, data)
+	default:
+		d := make([]any, len(data))
+		for i, dd := range data {
+			if g, ok := dd.(DataGetter); ok {
+				d[i] = g.TableGetData()
+			} else {
+				d[i] = dd
+			}
+		}
+		return FPrintData(out, d)
+	}
+}
+
+func fPrintCSV(out io.Writer, data []TableWriter) error {
+	if len(data) == 0 {
+		return nil
+	}
+	w := csv.NewWriter(out)
+	if err := w.Write(data[0].TableGetHeader()); err != nil {
+		return err
+	}
+	for _, d := range data {
+		if err := w.Write(d.TableGetRow()); err != nil {
+			return err
+		}
+	}
+	w.Flush()
+	return w.Error()
+}
+
+// PrintData prints non-tabular data to stdout, see FPrintData
+func PrintData(data any) error {
+	return FPrintData(os.Stdout, data)
+}
+
+// FPrintData prints non-tabular data as yaml if the yaml output format is used, as one line per element if the json
+// lines output format is used, and as indented json otherwise
+func FPrintData(out io.Writer, data any) error {
+	var jsonData []byte
+	switch v := data.(type) {
+	case []byte:
+		jsonData = v
+	default:
+		var err error
+		jsonData, err = json.Marshal(data)
+		if err != nil {
+			return errors.Wrap(err, "internal error")
+		}
+	}
+	if format == FormatJSONLines {
+		return fPrintJSONLines(out, jsonData)
+	}
+	if format != FormatYAML {
+		var buf bytes.Buffer
+		if err := json.Indent(&buf, jsonData, "", "  "); err != nil {
+			return err
+		}
+		_, err := fmt.Fprintln(out, buf.String())
+		return err
+	}
+	// json is valid yaml; decoding it into a node keeps the order of the fields and the json field names
+	var node yaml.Node
+	if err := yaml.Unmarshal(jsonData, &node); err != nil {
+		return err
+	}
+	resetStyle(&node)
+	y, err := yaml.Marshal(&node)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprint(out, string(y))
+	return err
+}
+
+// fPrintJSONLines prints each element of a json array as a compact json line; other json values are printed as a
+// single line
+func fPrintJSONLines(out io.Writer, jsonData []byte) error {
+	var elements []json.RawMessage
+	if err := json.Unmarshal(jsonData, &elements); err != nil {
+		elements = []json.RawMessage{jsonData}
+	}
+	for _, e := range elements {
+		var buf bytes.Buffer
+		if err := json.Compact(&buf, e); err != nil {
+			return err
+		}
+		if _, err := fmt.Fprintln(out, buf.String()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// resetStyle changes the flow style of a node that was decoded from json to the default block style
+func resetStyle(node *yaml.Node) {
+	node.Style = 0
+	for _, n := range node.Content {
+		resetStyle(n)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"os"
 
 	"github.com/olekukonko/tablewriter"
+	log "github.com/sirupsen/logrus"
 )
 
 func PrintTable(headers []string, data [][]string) {
@@ -27,6 +28,12 @@ func PrintTableData(data []TableWriter) {
 }
 
 func FPrintTableData(out *os.File, data []TableWriter) {
+	if MachineReadable() {
+		if err := fPrintTableDataFormatted(out, data); err != nil {
+			log.WithError(err).Error("could not print output")
+		}
+		return
+	}
 	if len(data) == 0 {
 		return
 	}
@@ -0,0 +1,254 @@
+// Package timeexpr parses absolute and relative time expressions given on the command line
+package timeexpr
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/oidc-mytoken/utils/utils/duration"
+	"github.com/pkg/errors"
+)
+
+// Help describes the supported time expressions; it is meant to be used in flag usages
+const Help = "The time can be given as a unix timestamp, an ISO 8601 time (e.g. '2026-03-01T12:00:00+01:00'), " +
+	"'2006-01-02 15:04' in the local time zone, a duration from now (e.g. '+1d', '7d', '2w', '1d12h', 'P7D', " +
+	"'PT36H'), or a phrase ('now', 'today 18:00', 'tomorrow 09:00', 'end-of-day', 'end-of-week', " +
+	"'end-of-month', 'end-of-year')."
+
+var isoDurationRegex = regexp.MustCompile(
+	`^P(?:(\d+)Y)?(?:(\d+)M)?(?:(\d+)W)?(?:(\d+)D)?(?:T(?:(\d+)H)?(?:(\d+)M)?(?:(\d+)S)?)?$`,
+)
+
+var clockRegex = regexp.MustCompile(`^(\d{1,2}):(\d{2})$`)
+
+var isoLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04Z07:00",
+}
+
+var localLayouts = []string{
+	"2006-01-02T15:04:05",
+	"2006-01-02T15:04",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02",
+}
+
+// Parse parses a time expression relative to now and returns it as a unix timestamp; an empty string results in 0
+func Parse(s string, now time.Time) (int64, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, nil
+	}
+	if isDigits(s) {
+		t, err := strconv.ParseInt(s, 10, 64)
+		return t, errors.WithStack(err)
+	}
+	if s[0] == '+' {
+		return parseOffset(s[1:], now)
+	}
+	t, ok, err := parse(s, now)
+	if err != nil {
+		return 0, err
+	}
+	if !ok {
+		return 0, errors.Errorf("cannot parse time '%s'", s)
+	}
+	return t.Unix(), nil
+}
+
+// PastHelp describes the supported time expressions for points in the past; it is meant to be used in flag usages
+const PastHelp = "The time can be given as a unix timestamp, an ISO 8601 time, '2006-01-02 15:04' in the local " +
+	"time zone, a duration before now (e.g. '30m', '24h', '7d', 'P1W'), or a phrase ('today', 'now')."
+
+// ParsePast parses a time expression like Parse, but durations are counted backwards from now, i.e. '7d' and '-7d'
+// mean seven days ago
+func ParsePast(s string, now time.Time) (int64, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, nil
+	}
+	if s[0] == '-' {
+		s = s[1:]
+	}
+	if s != "" && !isDigits(s) {
+		if t, ok := parseISODuration(s, now); ok {
+			return now.Add(-t.Sub(now)).Unix(), nil
+		}
+		if s[0] >= '0' && s[0] <= '9' {
+			if d, err := duration.ParseDuration(s); err == nil {
+				return now.Add(-d).Unix(), nil
+			}
+		}
+	}
+	return Parse(s, now)
+}
+
+// parseOffset parses the duration after a '+'; a plain number is a number of seconds
+func parseOffset(s string, now time.Time) (int64, error) {
+	if isDigits(s) && s != "" {
+		secs, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return 0, errors.WithStack(err)
+		}
+		return now.Add(time.Duration(secs) * time.Second).Unix(), nil
+	}
+	d, err := duration.ParseDuration(s)
+	if err != nil {
+		return 0, errors.Errorf("cannot parse duration '+%s'", s)
+	}
+	return now.Add(d).Unix(), nil
+}
+
+func parse(s string, now time.Time) (time.Time, bool, error) {
+	for _, layout := range isoLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, true, nil
+		}
+	}
+	for _, layout := range localLayouts {
+		if t, err := time.ParseInLocation(layout, s, now.Location()); err == nil {
+			return t, true, nil
+		}
+	}
+	if t, ok := parseISODuration(s, now); ok {
+		return t, true, nil
+	}
+	if s[0] >= '0' && s[0] <= '9' {
+		d, err := duration.ParseDuration(s)
+		if err != nil {
+			return time.Time{}, false, errors.Errorf("cannot parse time or duration '%s'", s)
+		}
+		return now.Add(d), true, nil
+	}
+	return parsePhrase(s, now)
+}
+
+func parseISODuration(s string, now time.Time) (time.Time, bool) {
+	m := isoDurationRegex.FindStringSubmatch(strings.ToUpper(s))
+	if m == nil || s == "P" || strings.HasSuffix(strings.ToUpper(s), "T") {
+		return time.Time{}, false
+	}
+	n := make([]int, len(m))
+	for i := 1; i < len(m); i++ {
+		if m[i] != "" {
+			n[i], _ = strconv.Atoi(m[i])
+		}
+	}
+	t := now.AddDate(n[1], n[2], 7*n[3]+n[4])
+	t = t.Add(
+		time.Duration(n[5])*time.Hour + time.Duration(n[6])*time.Minute + time.Duration(n[7])*time.Second,
+	)
+	return t, true
+}
+
+func parsePhrase(s string, now time.Time) (time.Time, bool, error) {
+	fields := strings.Fields(strings.ToLower(s))
+	if len(fields) == 0 || len(fields) > 2 {
+		return time.Time{}, false, nil
+	}
+	y, mo, d := now.Date()
+	loc := now.Location()
+	var dayOffset int
+	switch fields[0] {
+	case "now":
+		if len(fields) > 1 {
+			return time.Time{}, false, nil
+		}
+		return now, true, nil
+	case "today":
+	case "tomorrow":
+		dayOffset = 1
+	case "end-of-day", "eod":
+		return endOfDay(y, mo, d, loc), len(fields) == 1, nil
+	case "end-of-week", "eow":
+		// weeks end on sunday
+		offset := (7 - int(now.Weekday())) % 7
+		return endOfDay(y, mo, d+offset, loc), len(fields) == 1, nil
+	case "end-of-month", "eom":
+		return endOfDay(y, mo+1, 0, loc), len(fields) == 1, nil
+	case "end-of-year", "eoy":
+		return endOfDay(y, time.December, 31, loc), len(fields) == 1, nil
+	default:
+		return time.Time{}, false, nil
+	}
+	if len(fields) == 1 {
+		return time.Date(y, mo, d+dayOffset, 0, 0, 0, 0, loc), true, nil
+	}
+	m := clockRegex.FindStringSubmatch(fields[1])
+	if m == nil {
+		return time.Time{}, false, errors.Errorf("invalid time of day '%s' in '%s'; use HH:MM", fields[1], s)
+	}
+	hour, _ := strconv.Atoi(m[1])
+	minute, _ := strconv.Atoi(m[2])
+	if hour > 23 || minute > 59 {
+		return time.Time{}, false, errors.Errorf("invalid time of day '%s' in '%s'", fields[1], s)
+	}
+	// time.Date is used instead of adding hours to midnight, so the wall clock time is also correct on days with a
+	// daylight saving time change
+	return time.Date(y, mo, d+dayOffset, hour, minute, 0, 0, loc), true, nil
+}
+
+// endOfDay returns the last second of the passed day
+func endOfDay(year int, month time.Month, day int, loc *time.Location) time.Time {
+	return time.Date(year, month, day, 23, 59, 59, 0, loc)
+}
+
+func isDigits(s string) bool {
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// Describe returns the passed unix timestamp in the local time zone and in UTC, e.g.
+// '2026-03-01 13:00:00 CET (2026-03-01 12:00:00 UTC)'
+func Describe(unix int64) string {
+	const timeFmt = "2006-01-02 15:04:05 MST"
+	t := time.Unix(unix, 0)
+	return t.Local().Format(timeFmt) + " (" + t.UTC().Format(timeFmt) + ")"
+}
+
+// FormatDuration formats a duration with the two most significant units, e.g. '2d 4h', '35m 10s', or '12s'
+func FormatDuration(d time.Duration) string {
+	if d < 0 {
+		d = -d
+	}
+	d = d.Round(time.Second)
+	units := []struct {
+		name string
+		d    time.Duration
+	}{
+		{"d", 24 * time.Hour},
+		{"h", time.Hour},
+		{"m", time.Minute},
+		{"s", time.Second},
+	}
+	for i, u := range units {
+		if d < u.d {
+			continue
+		}
+		res := strconv.FormatInt(int64(d/u.d), 10) + u.name
+		if i+1 < len(units) {
+			next := units[i+1]
+			if n := (d % u.d) / next.d; n > 0 {
+				res += " " + strconv.FormatInt(int64(n), 10) + next.name
+			}
+		}
+		return res
+	}
+	return "0s"
+}
+
+// Relative describes the passed unix timestamp relative to now, e.g. 'in 2d 4h' or '3d 1h ago'
+func Relative(unix int64, now time.Time) string {
+	d := time.Unix(unix, 0).Sub(now)
+	if d >= 0 {
+		return "in " + FormatDuration(d)
+	}
+	return FormatDuration(d) + " ago"
+}
@@ -0,0 +1,180 @@
+package timeexpr
+
+import (
+	"testing"
+	"time"
+)
+
+// now is a wednesday
+var now = time.Date(2026, time.March, 4, 10, 30, 0, 0, time.UTC)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want time.Time
+	}{
+		{name: "iso", in: "2026-03-01T12:00:00+01:00", want: time.Date(2026, time.March, 1, 11, 0, 0, 0, time.UTC)},
+		{
+			name: "iso without seconds", in: "2026-03-01T12:00Z",
+			want: time.Date(2026, time.March, 1, 12, 0, 0, 0, time.UTC),
+		},
+		{name: "local", in: "2026-03-01 15:04", want: time.Date(2026, time.March, 1, 15, 4, 0, 0, time.UTC)},
+		{name: "date", in: "2026-03-01", want: time.Date(2026, time.March, 1, 0, 0, 0, 0, time.UTC)},
+		{name: "offset seconds", in: "+60", want: now.Add(time.Minute)},
+		{name: "offset", in: "+1d", want: now.Add(24 * time.Hour)},
+		{name: "offset combined", in: "+1d12h", want: now.Add(36 * time.Hour)},
+		{name: "duration", in: "7d", want: now.Add(7 * 24 * time.Hour)},
+		{name: "weeks", in: "2w", want: now.Add(14 * 24 * time.Hour)},
+		{name: "iso duration", in: "P7D", want: now.AddDate(0, 0, 7)},
+		{name: "iso duration lower case", in: "p1w", want: now.AddDate(0, 0, 7)},
+		{name: "iso duration time", in: "PT36H", want: now.Add(36 * time.Hour)},
+		{name: "iso duration months", in: "P1M", want: now.AddDate(0, 1, 0)},
+		{name: "now", in: "now", want: now},
+		{name: "surrounding space", in: "  now ", want: now},
+		{name: "today", in: "today", want: time.Date(2026, time.March, 4, 0, 0, 0, 0, time.UTC)},
+		{name: "today with time", in: "today 18:00", want: time.Date(2026, time.March, 4, 18, 0, 0, 0, time.UTC)},
+		{name: "tomorrow", in: "tomorrow 09:00", want: time.Date(2026, time.March, 5, 9, 0, 0, 0, time.UTC)},
+		{name: "phrase case", in: "Tomorrow 9:05", want: time.Date(2026, time.March, 5, 9, 5, 0, 0, time.UTC)},
+		{name: "end of day", in: "end-of-day", want: time.Date(2026, time.March, 4, 23, 59, 59, 0, time.UTC)},
+		{name: "end of week", in: "eow", want: time.Date(2026, time.March, 8, 23, 59, 59, 0, time.UTC)},
+		{name: "end of month", in: "end-of-month", want: time.Date(2026, time.March, 31, 23, 59, 59, 0, time.UTC)},
+		{name: "end of year", in: "end-of-year", want: time.Date(2026, time.December, 31, 23, 59, 59, 0, time.UTC)},
+	}
+	for _, test := range tests {
+		t.Run(
+			test.name, func(t *testing.T) {
+				got, err := Parse(test.in, now)
+				if err != nil {
+					t.Fatalf("unexpected error: %s", err)
+				}
+				if want := test.want.Unix(); got != want {
+					t.Errorf("expected %s, got %s", time.Unix(want, 0).UTC(), time.Unix(got, 0).UTC())
+				}
+			},
+		)
+	}
+}
+
+func TestParseTimestampAndEmpty(t *testing.T) {
+	tests := []struct {
+		in   string
+		want int64
+	}{
+		{in: "", want: 0},
+		{in: "   ", want: 0},
+		{in: "1700000000", want: 1700000000},
+	}
+	for _, test := range tests {
+		got, err := Parse(test.in, now)
+		if err != nil {
+			t.Fatalf("'%s': unexpected error: %s", test.in, err)
+		}
+		if got != test.want {
+			t.Errorf("'%s': expected %d, got %d", test.in, test.want, got)
+		}
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	for _, in := range []string{
+		"foo",
+		"+x",
+		"P",
+		"PT",
+		"today 9",
+		"tomorrow 25:00",
+		"today 12:60",
+		"now 12:00",
+		"end-of-day 12:00",
+		"next week please",
+		"7x",
+	} {
+		if got, err := Parse(in, now); err == nil {
+			t.Errorf("'%s': expected an error, got %s", in, time.Unix(got, 0).UTC())
+		}
+	}
+}
+
+func TestParseDaylightSavingTime(t *testing.T) {
+	loc, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skipf("time zone data not available: %s", err)
+	}
+	// the clocks are set forward on 2026-03-29 at 02:00
+	beforeChange := time.Date(2026, time.March, 28, 12, 0, 0, 0, loc)
+	tests := []struct {
+		name string
+		in   string
+		want time.Time
+	}{
+		{name: "wall clock", in: "tomorrow 09:00", want: time.Date(2026, time.March, 29, 7, 0, 0, 0, time.UTC)},
+		{name: "duration", in: "+1d", want: time.Date(2026, time.March, 29, 11, 0, 0, 0, time.UTC)},
+		{name: "end of day", in: "end-of-week", want: time.Date(2026, time.March, 29, 21, 59, 59, 0, time.UTC)},
+		{name: "local", in: "2026-03-29 12:00", want: time.Date(2026, time.March, 29, 10, 0, 0, 0, time.UTC)},
+	}
+	for _, test := range tests {
+		t.Run(
+			test.name, func(t *testing.T) {
+				got, err := Parse(test.in, beforeChange)
+				if err != nil {
+					t.Fatalf("unexpected error: %s", err)
+				}
+				if want := test.want.Unix(); got != want {
+					t.Errorf("expected %s, got %s", time.Unix(want, 0).UTC(), time.Unix(got, 0).UTC())
+				}
+			},
+		)
+	}
+}
+
+func TestParsePast(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want int64
+	}{
+		{name: "empty", in: "", want: 0},
+		{name: "timestamp", in: "1700000000", want: 1700000000},
+		{name: "duration", in: "7d", want: now.Add(-7 * 24 * time.Hour).Unix()},
+		{name: "negative duration", in: "-7d", want: now.Add(-7 * 24 * time.Hour).Unix()},
+		{name: "hours", in: "24h", want: now.Add(-24 * time.Hour).Unix()},
+		{name: "minutes", in: "30m", want: now.Add(-30 * time.Minute).Unix()},
+		{name: "iso duration", in: "P1W", want: now.AddDate(0, 0, -7).Unix()},
+		{name: "today", in: "today", want: time.Date(2026, time.March, 4, 0, 0, 0, 0, time.UTC).Unix()},
+		{name: "iso", in: "2026-03-01T12:00:00Z", want: time.Date(2026, time.March, 1, 12, 0, 0, 0, time.UTC).Unix()},
+	}
+	for _, test := range tests {
+		t.Run(
+			test.name, func(t *testing.T) {
+				got, err := ParsePast(test.in, now)
+				if err != nil {
+					t.Fatalf("unexpected error: %s", err)
+				}
+				if got != test.want {
+					t.Errorf("expected %s, got %s", time.Unix(test.want, 0).UTC(), time.Unix(got, 0).UTC())
+				}
+			},
+		)
+	}
+}
+
+func TestFormatDuration(t *testing.T) {
+	tests := []struct {
+		in   time.Duration
+		want string
+	}{
+		{in: 0, want: "0s"},
+		{in: 400 * time.Millisecond, want: "0s"},
+		{in: 12 * time.Second, want: "12s"},
+		{in: 35*time.Minute + 10*time.Second, want: "35m 10s"},
+		{in: time.Hour + 30*time.Second, want: "1h"},
+		{in: 52 * time.Hour, want: "2d 4h"},
+		{in: -90 * time.Second, want: "1m 30s"},
+	}
+	for _, test := range tests {
+		if got := FormatDuration(test.in); got != test.want {
+			t.Errorf("%s: expected '%s', got '%s'", test.in, test.want, got)
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package tokenfile
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"net"
+	"os"
+
+	"github.com/Songmu/prompter"
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/scrypt"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+// PassphraseEnv is the environment variable that can hold the passphrase for encrypted mytoken files
+const PassphraseEnv = "MYTOKEN_FILE_PASSPHRASE"
+
+const sshSignaturePrefix = "mytoken-file-encryption:"
+
+var promptDisabled bool
+
+// DisablePrompt makes reading a passphrase-encrypted file fail instead of prompting for the passphrase if it is not
+// set in the PassphraseEnv environment variable
+func DisablePrompt() {
+	promptDisabled = true
+}
+
+func getPassphrase(path string, confirm bool) (string, error) {
+	if passphrase, ok := os.LookupEnv(PassphraseEnv); ok {
+		return passphrase, nil
+	}
+	if promptDisabled {
+		return "", errors.Errorf("no passphrase for mytoken file '%s' in %s", path, PassphraseEnv)
+	}
+	passphrase := prompter.Password(fmt.Sprintf("Enter passphrase for mytoken file '%s'", path))
+	if passphrase == "" {
+		return "", errors.New("no passphrase given")
+	}
+	if confirm && prompter.Password("Confirm passphrase") != passphrase {
+		return "", errors.New("passphrases do not match")
+	}
+	return passphrase, nil
+}
+
+func deriveKeyFromPassphrase(salt []byte, path string, confirm bool) ([]byte, error) {
+	passphrase, err := getPassphrase(path, confirm)
+	if err != nil {
+		return nil, err
+	}
+	return scrypt.Key([]byte(passphrase), salt, 1<<15, 8, 1, 32)
+}
+
+func sshAgent() (agent.Agent, error) {
+	sock := os.Getenv("SSH_AUTH_SOCK")
+	if sock == "" {
+		return nil, errors.New("SSH_AUTH_SOCK not set; cannot connect to ssh-agent")
+	}
+	conn, err := net.Dial("unix", sock)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not connect to ssh-agent")
+	}
+	return agent.NewClient(conn), nil
+}
+
+// usableForEncryption checks if signatures of the passed key are deterministic, which is required to always derive
+// the same key from them
+func usableForEncryption(key ssh.PublicKey) bool {
+	switch key.Type() {
+	case ssh.KeyAlgoED25519, ssh.KeyAlgoRSA:
+		return true
+	default:
+		return false
+	}
+}
+
+func findSSHKey(a agent.Agent, fingerprint string) (ssh.PublicKey, error) {
+	keys, err := a.List()
+	if err != nil {
+		return nil, errors.Wrap(err, "could not list ssh-agent keys")
+	}
+	for _, k := range keys {
+		if !usableForEncryption(k) {
+			continue
+		}
+		if fingerprint == "" || ssh.FingerprintSHA256(k) == fingerprint {
+			return k, nil
+		}
+	}
+	if fingerprint == "" {
+		return nil, errors.New("no ed25519 or rsa key found in ssh-agent")
+	}
+	return nil, errors.Errorf("ssh key '%s' not found in ssh-agent or not an ed25519 or rsa key", fingerprint)
+}
+
+func selectSSHKey(fingerprint string) (string, error) {
+	a, err := sshAgent()
+	if err != nil {
+		return "", err
+	}
+	k, err := findSSHKey(a, fingerprint)
+	if err != nil {
+		return "", err
+	}
+	return ssh.FingerprintSHA256(k), nil
+}
+
+func deriveKeyFromSSHAgent(fingerprint string, salt []byte) ([]byte, error) {
+	a, err := sshAgent()
+	if err != nil {
+		return nil, err
+	}
+	k, err := findSSHKey(a, fingerprint)
+	if err != nil {
+		return nil, err
+	}
+	sig, err := a.Sign(k, append([]byte(sshSignaturePrefix), salt...))
+	if err != nil {
+		return nil, errors.Wrap(err, "ssh-agent could not sign")
+	}
+	key := sha256.Sum256(sig.Blob)
+	return key[:], nil
+}
@@ -0,0 +1,215 @@
+// Package tokenfile reads and writes files containing a mytoken. Such a file either contains the plain mytoken in
+// its first line or the encrypted mytoken. Encrypted files are opened with a passphrase or with a key derived from an
+// ssh-agent signature.
+package tokenfile
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// The supported encryption methods
+const (
+	MethodPassphrase = "passphrase"
+	MethodSSHAgent   = "ssh-agent"
+)
+
+const formatVersion = 1
+
+// encryptedFile is the content of an encrypted mytoken file
+type encryptedFile struct {
+	Version    int    `json:"mytoken_encrypted"`
+	Method     string `json:"method"`
+	SSHKey     string `json:"ssh_key,omitempty"`
+	Salt       []byte `json:"salt"`
+	Nonce      []byte `json:"nonce"`
+	Ciphertext []byte `json:"ciphertext"`
+}
+
+// fileKey holds the key material of an encrypted file that was read, so the file can be re-encrypted on write
+type fileKey struct {
+	method string
+	sshKey string
+	salt   []byte
+	key    []byte
+}
+
+var (
+	keysMutex sync.Mutex
+	keys      = make(map[string]fileKey)
+)
+
+// IsEncrypted checks if the passed file content is an encrypted mytoken file
+func IsEncrypted(content []byte) bool {
+	content = bytes.TrimSpace(content)
+	if len(content) == 0 || content[0] != '{' {
+		return false
+	}
+	var f encryptedFile
+	return json.Unmarshal(content, &f) == nil && f.Version > 0
+}
+
+// Read returns the mytoken stored in the passed file; encrypted files are decrypted transparently
+func Read(path string) (string, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	if !IsEncrypted(content) {
+		return strings.SplitN(string(content), "\n", 2)[0], nil
+	}
+	var f encryptedFile
+	if err = json.Unmarshal(bytes.TrimSpace(content), &f); err != nil {
+		return "", errors.Wrapf(err, "could not parse encrypted mytoken file %s", path)
+	}
+	if f.Version > formatVersion {
+		return "", errors.Errorf("unsupported version %d of encrypted mytoken file %s", f.Version, path)
+	}
+	key, err := deriveKey(f.Method, f.SSHKey, f.Salt, path, false)
+	if err != nil {
+		return "", err
+	}
+	token, err := decrypt(key, f.Nonce, f.Ciphertext)
+	if err != nil {
+		return "", errors.Wrapf(err, "could not decrypt mytoken file %s", path)
+	}
+	keysMutex.Lock()
+	keys[path] = fileKey{
+		method: f.Method,
+		sshKey: f.SSHKey,
+		salt:   f.Salt,
+		key:    key,
+	}
+	keysMutex.Unlock()
+	return token, nil
+}
+
+// Write writes the passed mytoken to the passed file. If the file was read encrypted before, it is encrypted again
+// with the same key; otherwise the plain mytoken is written.
+func Write(path, token string) error {
+	keysMutex.Lock()
+	k, ok := keys[path]
+	keysMutex.Unlock()
+	if !ok {
+		return os.WriteFile(path, []byte(token), 0600)
+	}
+	return writeEncrypted(path, token, k)
+}
+
+// Encrypt writes the passed mytoken encrypted with the passed method to the passed file. For MethodSSHAgent sshKey
+// selects the key from the ssh-agent by its SHA256 fingerprint; if it is empty, the first suitable key is used.
+func Encrypt(path, token, method, sshKey string) error {
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return err
+	}
+	if method == MethodSSHAgent {
+		fp, err := selectSSHKey(sshKey)
+		if err != nil {
+			return err
+		}
+		sshKey = fp
+	}
+	key, err := deriveKey(method, sshKey, salt, path, true)
+	if err != nil {
+		return err
+	}
+	k := fileKey{
+		method: method,
+		sshKey: sshKey,
+		salt:   salt,
+		key:    key,
+	}
+	if err = writeEncrypted(path, token, k); err != nil {
+		return err
+	}
+	keysMutex.Lock()
+	keys[path] = k
+	keysMutex.Unlock()
+	return nil
+}
+
+// Decrypt replaces the encrypted mytoken in the passed file with the plain mytoken
+func Decrypt(path string) error {
+	token, err := Read(path)
+	if err != nil {
+		return err
+	}
+	keysMutex.Lock()
+	delete(keys, path)
+	keysMutex.Unlock()
+	return os.WriteFile(path, []byte(token), 0600)
+}
+
+func writeEncrypted(path, token string, k fileKey) error {
+	nonce, ciphertext, err := encrypt(k.key, token)
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(
+		encryptedFile{
+			Version:    formatVersion,
+			Method:     k.method,
+			SSHKey:     k.sshKey,
+			Salt:       k.salt,
+			Nonce:      nonce,
+			Ciphertext: ciphertext,
+		},
+	)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0600)
+}
+
+func deriveKey(method, sshKey string, salt []byte, path string, newFile bool) ([]byte, error) {
+	switch method {
+	case MethodPassphrase:
+		return deriveKeyFromPassphrase(salt, path, newFile)
+	case MethodSSHAgent:
+		return deriveKeyFromSSHAgent(sshKey, salt)
+	default:
+		return nil, errors.Errorf("unknown encryption method '%s'", method)
+	}
+}
+
+func encrypt(key []byte, token string) (nonce, ciphertext []byte, err error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return
+	}
+	nonce = make([]byte, gcm.NonceSize())
+	if _, err = rand.Read(nonce); err != nil {
+		return
+	}
+	ciphertext = gcm.Seal(nil, nonce, []byte(token), nil)
+	return
+}
+
+func decrypt(key, nonce, ciphertext []byte) (string, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return "", err
+	}
+	plain, err := gcm.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return "", errors.New("wrong passphrase or key")
+	}
+	return string(plain), nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,130 @@
+package tui
+
+import (
+	"unicode/utf8"
+)
+
+// KeyCode identifies a key; printable characters have the code KeyRune
+type KeyCode int
+
+// The supported keys
+const (
+	KeyRune KeyCode = iota
+	KeyUp
+	KeyDown
+	KeyLeft
+	KeyRight
+	KeyHome
+	KeyEnd
+	KeyPgUp
+	KeyPgDown
+	KeyDelete
+	KeyF5
+	KeyEnter
+	KeyTab
+	KeyBacktab
+	KeyBackspace
+	KeyEsc
+	KeyCtrlC
+)
+
+// Key is a pressed key
+type Key struct {
+	Code KeyCode
+	Rune rune
+}
+
+// csiTildeKeys maps the parameter of 'ESC [ n ~' sequences to keys
+var csiTildeKeys = map[string]KeyCode{
+	"1":  KeyHome,
+	"3":  KeyDelete,
+	"4":  KeyEnd,
+	"5":  KeyPgUp,
+	"6":  KeyPgDown,
+	"7":  KeyHome,
+	"8":  KeyEnd,
+	"15": KeyF5,
+}
+
+// csiFinalKeys maps the final byte of 'ESC [ x' and 'ESC O x' sequences to keys
+var csiFinalKeys = map[byte]KeyCode{
+	'A': KeyUp,
+	'B': KeyDown,
+	'C': KeyRight,
+	'D': KeyLeft,
+	'H': KeyHome,
+	'F': KeyEnd,
+	'Z': KeyBacktab,
+}
+
+// parseKeys parses the bytes of a single read from the terminal; an escape byte that is not followed by a sequence
+// is the escape key
+func parseKeys(b []byte) []Key {
+	var keys []Key
+	for len(b) > 0 {
+		switch c := b[0]; {
+		case c == 0x1b:
+			k, n := parseEscapeSequence(b)
+			if n == 0 {
+				keys = append(keys, Key{Code: KeyEsc})
+				b = b[1:]
+				continue
+			}
+			if k != nil {
+				keys = append(keys, *k)
+			}
+			b = b[n:]
+		case c == '\r' || c == '\n':
+			keys = append(keys, Key{Code: KeyEnter})
+			b = b[1:]
+		case c == '\t':
+			keys = append(keys, Key{Code: KeyTab})
+			b = b[1:]
+		case c == 0x7f || c == 0x08:
+			keys = append(keys, Key{Code: KeyBackspace})
+			b = b[1:]
+		case c == 0x03:
+			keys = append(keys, Key{Code: KeyCtrlC})
+			b = b[1:]
+		case c < 0x20:
+			b = b[1:]
+		default:
+			r, n := utf8.DecodeRune(b)
+			if r != utf8.RuneError {
+				keys = append(keys, Key{Code: KeyRune, Rune: r})
+			}
+			b = b[n:]
+		}
+	}
+	return keys
+}
+
+// parseEscapeSequence parses the escape sequence at the start of b; it returns the number of consumed bytes, which
+// is 0 if b does not start with an escape sequence, and nil if the sequence is not a supported key
+func parseEscapeSequence(b []byte) (*Key, int) {
+	if len(b) < 3 || (b[1] != '[' && b[1] != 'O') {
+		return nil, 0
+	}
+	for i := 2; i < len(b); i++ {
+		c := b[i]
+		if c >= '0' && c <= '9' || c == ';' {
+			continue
+		}
+		if c < 0x40 || c > 0x7e {
+			return nil, i + 1
+		}
+		if c == '~' {
+			code, ok := csiTildeKeys[string(b[2:i])]
+			if !ok {
+				return nil, i + 1
+			}
+			return &Key{Code: code}, i + 1
+		}
+		code, ok := csiFinalKeys[c]
+		if !ok {
+			return nil, i + 1
+		}
+		return &Key{Code: code}, i + 1
+	}
+	return nil, len(b)
+}
@@ -0,0 +1,103 @@
+// Package tui provides a minimal full-screen terminal interface
+package tui
+
+import (
+	"os"
+	"strings"
+
+	"github.com/pkg/errors"
+	"golang.org/x/term"
+)
+
+const (
+	enterAlternateScreen = "\x1b[?1049h"
+	leaveAlternateScreen = "\x1b[?1049l"
+	hideCursor           = "\x1b[?25l"
+	showCursor           = "\x1b[?25h"
+	cursorHome           = "\x1b[H"
+	clearScreen          = "\x1b[2J"
+	resetAndClearLine    = "\x1b[0m\x1b[K"
+)
+
+// Screen is a full-screen terminal session on the alternate screen with the terminal in raw mode
+type Screen struct {
+	in    *os.File
+	out   *os.File
+	state *term.State
+	keys  chan Key
+}
+
+// Open switches the terminal to raw mode and to the alternate screen; it fails if stdin or stdout is not a terminal.
+// Close must be called to restore the terminal.
+func Open() (*Screen, error) {
+	in, out := os.Stdin, os.Stdout
+	if !term.IsTerminal(int(in.Fd())) || !term.IsTerminal(int(out.Fd())) {
+		return nil, errors.New("stdin and stdout must be a terminal")
+	}
+	state, err := term.MakeRaw(int(in.Fd()))
+	if err != nil {
+		return nil, errors.Wrap(err, "could not switch the terminal to raw mode")
+	}
+	enableVirtualTerminal(out)
+	s := &Screen{
+		in:    in,
+		out:   out,
+		state: state,
+		keys:  make(chan Key, 16),
+	}
+	_, _ = s.out.WriteString(enterAlternateScreen + hideCursor + clearScreen)
+	go s.readKeys()
+	return s, nil
+}
+
+// Close leaves the alternate screen and restores the terminal
+func (s *Screen) Close() {
+	_, _ = s.out.WriteString(resetAndClearLine + showCursor + leaveAlternateScreen)
+	_ = term.Restore(int(s.in.Fd()), s.state)
+}
+
+// Size returns the width and height of the terminal
+func (s *Screen) Size() (width, height int) {
+	width, height, err := term.GetSize(int(s.out.Fd()))
+	if err != nil || width <= 0 || height <= 0 {
+		return 80, 24
+	}
+	return width, height
+}
+
+// Keys returns the channel of pressed keys; it is closed when stdin is closed
+func (s *Screen) Keys() <-chan Key {
+	return s.keys
+}
+
+// Draw replaces the screen content with the passed lines; lines are truncated and padded to the terminal width and
+// missing lines are cleared
+func (s *Screen) Draw(lines []string) {
+	width, height := s.Size()
+	var b strings.Builder
+	b.WriteString(cursorHome)
+	for i := 0; i < height; i++ {
+		if i < len(lines) {
+			b.WriteString(Truncate(lines[i], width))
+		}
+		b.WriteString(resetAndClearLine)
+		if i < height-1 {
+			b.WriteString("\r\n")
+		}
+	}
+	_, _ = s.out.WriteString(b.String())
+}
+
+func (s *Screen) readKeys() {
+	defer close(s.keys)
+	buf := make([]byte, 256)
+	for {
+		n, err := s.in.Read(buf)
+		if err != nil {
+			return
+		}
+		for _, k := range parseKeys(buf[:n]) {
+			s.keys <- k
+		}
+	}
+}
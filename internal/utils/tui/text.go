@@ -0,0 +1,84 @@
+package tui
+
+import (
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/mattn/go-runewidth"
+)
+
+var ansiRegex = regexp.MustCompile("\x1b\\[[0-9;?]*[A-Za-z]")
+
+// StripANSI removes ANSI escape sequences, e.g. colors, from a string
+func StripANSI(s string) string {
+	return ansiRegex.ReplaceAllString(s, "")
+}
+
+// Width returns the number of terminal cells needed to display a string; escape sequences do not count
+func Width(s string) int {
+	return runewidth.StringWidth(StripANSI(s))
+}
+
+// Truncate cuts a string to at most width terminal cells; escape sequences are kept and attributes are reset at the
+// end of a truncated string, so colors do not leak into the following text
+func Truncate(s string, width int) string {
+	if Width(s) <= width {
+		return s
+	}
+	var b strings.Builder
+	w := 0
+	styled := false
+	for len(s) > 0 {
+		if loc := ansiRegex.FindStringIndex(s); loc != nil && loc[0] == 0 {
+			b.WriteString(s[:loc[1]])
+			s = s[loc[1]:]
+			styled = true
+			continue
+		}
+		r, n := utf8.DecodeRuneInString(s)
+		rw := runewidth.RuneWidth(r)
+		if w+rw > width {
+			break
+		}
+		b.WriteRune(r)
+		w += rw
+		s = s[n:]
+	}
+	if styled {
+		b.WriteString("\x1b[0m")
+	}
+	return b.String()
+}
+
+// Pad truncates or pads a string with spaces to exactly width terminal cells
+func Pad(s string, width int) string {
+	s = Truncate(s, width)
+	if w := Width(s); w < width {
+		s += strings.Repeat(" ", width-w)
+	}
+	return s
+}
+
+// Wrap splits a string without escape sequences into lines of at most width terminal cells
+func Wrap(s string, width int) []string {
+	if width <= 0 {
+		return []string{s}
+	}
+	var lines []string
+	for Width(s) > width {
+		line := Truncate(s, width)
+		if line == "" {
+			break
+		}
+		lines = append(lines, line)
+		s = s[len(line):]
+	}
+	return append(lines, s)
+}
+
+// Reverse renders a string in reverse video, e.g. to highlight a selection; other attributes of the string are
+// dropped
+func Reverse(s string) string {
+	return "\x1b[7m" + StripANSI(s) + "\x1b[0m"
+}
@@ -0,0 +1,10 @@
+//go:build !windows
+
+package tui
+
+import (
+	"os"
+)
+
+// enableVirtualTerminal is a no-op; terminals on other systems process escape sequences
+func enableVirtualTerminal(*os.File) {}
@@ -0,0 +1,19 @@
+//go:build windows
+
+package tui
+
+import (
+	"os"
+
+	"golang.org/x/sys/windows"
+)
+
+// enableVirtualTerminal enables the processing of escape sequences by the windows console
+func enableVirtualTerminal(out *os.File) {
+	var mode uint32
+	h := windows.Handle(out.Fd())
+	if err := windows.GetConsoleMode(h, &mode); err != nil {
+		return
+	}
+	_ = windows.SetConsoleMode(h, mode|windows.ENABLE_VIRTUAL_TERMINAL_PROCESSING)
+}
@@ -0,0 +1,35 @@
+package wlcgtokendiscovery
+
+import (
+	"fmt"
+	"os"
+	"path"
+
+	"github.com/oidc-mytoken/client/internal/utils"
+)
+
+// TokenFileLocation returns the file in which a bearer token must be stored so that WLCG Bearer Token Discovery finds
+// it first: the file in BEARER_TOKEN_FILE if set, otherwise bt_u<uid> in XDG_RUNTIME_DIR if set, otherwise
+// /tmp/bt_u<uid>
+func TokenFileLocation() string {
+	if f, _ := os.LookupEnv("BEARER_TOKEN_FILE"); f != "" {
+		return f
+	}
+	name := fmt.Sprintf("bt_u%d", uid)
+	if d, _ := os.LookupEnv("XDG_RUNTIME_DIR"); d != "" {
+		return path.Join(d, name)
+	}
+	return path.Join("/tmp", name)
+}
+
+// ShadowedByEnv checks if the BEARER_TOKEN environment variable is set, which takes precedence over all token files
+func ShadowedByEnv() bool {
+	return lookInEnv() != ""
+}
+
+// WriteToken writes the passed token to the file returned by TokenFileLocation and returns the file's path; the file
+// is only readable by the user and replaced atomically, so readers never see a partially written token
+func WriteToken(token string) (string, error) {
+	file := TokenFileLocation()
+	return file, utils.WriteFileAtomic(file, []byte(token+"\n"), 0600)
+}